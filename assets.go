@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------- Catalogue des actifs et normalisation des symboles -------------------
+
+// Structure d'un actif retourné par l'endpoint Assets de Kraken
+type Asset struct {
+	Name            string  `json:"name"` // Nom interne (XXBT, ZUSD...)
+	AClass          string  `json:"aclass"`
+	AltName         string  `json:"altname"` // Nom alternatif (XBT, USD...)
+	Decimals        int     `json:"decimals"`
+	DisplayDecimals int     `json:"display_decimals"`
+	CollateralValue Decimal `json:"collateral_value"`
+	Status          string  `json:"status"`
+	Symbol          string  `json:"symbol"` // Symbole usuel (BTC, USD...)
+}
+
+// Noms Kraken historiques différents des symboles usuels
+var krakenSymbolAliases = map[string]string{
+	"XBT": "BTC",
+	"XDG": "DOGE",
+}
+
+// normalizeSymbol convertit un nom d'actif Kraken (XBT, XDG) en symbole usuel (BTC, DOGE)
+func normalizeSymbol(name string) string {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if symbol, ok := krakenSymbolAliases[name]; ok {
+		return symbol
+	}
+	return name
+}
+
+// GetAssets récupère tous les actifs disponibles, indexés par nom interne
+func (c *KrakenClient) GetAssets(ctx context.Context) (map[string]Asset, error) {
+	var response struct {
+		Error  []string         `json:"error"`
+		Result map[string]Asset `json:"result"`
+	}
+	if err := c.get(ctx, "/0/public/Assets", nil, &response); err != nil {
+		return nil, err
+	}
+	for name, asset := range response.Result {
+		asset.Name = name
+		asset.Symbol = normalizeSymbol(asset.AltName)
+		response.Result[name] = asset
+	}
+	return response.Result, nil
+}
+
+// SaveAssets enregistre ou met à jour les actifs dans une même transaction
+func SaveAssets(ctx context.Context, db *sql.DB, assets map[string]Asset) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO assets
+		(name, aclass, altname, decimals, display_decimals, collateral_value, status, symbol, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			aclass=excluded.aclass,
+			altname=excluded.altname,
+			decimals=excluded.decimals,
+			display_decimals=excluded.display_decimals,
+			collateral_value=excluded.collateral_value,
+			status=excluded.status,
+			symbol=excluded.symbol,
+			updated_at=excluded.updated_at`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for name, a := range assets {
+		if _, err := stmt.ExecContext(ctx, name, a.AClass, a.AltName, a.Decimals, a.DisplayDecimals,
+			a.CollateralValue, a.Status, a.Symbol, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RefreshAssets récupère le catalogue des actifs et l'enregistre
+func RefreshAssets(ctx context.Context, client *KrakenClient, db *sql.DB) (int, error) {
+	assets, err := client.GetAssets(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := SaveAssets(ctx, db, assets); err != nil {
+		return 0, err
+	}
+	return len(assets), nil
+}
+
+// Représentation canonique d'une paire, quel que soit le nom utilisé pour la désigner
+type PairSymbol struct {
+	Name   string `json:"name"`   // Nom interne Kraken (XXBTZUSD)
+	Pair   string `json:"pair"`   // Nom utilisé dans la base (XBTUSD)
+	Symbol string `json:"symbol"` // Forme canonique BASE/QUOTE (BTC/USD)
+	Base   string `json:"base"`   // Symbole usuel de l'actif de base (BTC)
+	Quote  string `json:"quote"`  // Symbole usuel de l'actif de cotation (USD)
+}
+
+// newPairSymbol construit la représentation canonique d'une paire. Sans catalogue
+// des actifs, les symboles sont déduits du nom WebSocket (XBT/USD).
+func newPairSymbol(name, altName, wsName, baseAltName, quoteAltName string) PairSymbol {
+	if baseAltName == "" || quoteAltName == "" {
+		baseAltName, quoteAltName, _ = strings.Cut(wsName, "/")
+	}
+	ps := PairSymbol{
+		Name:  name,
+		Pair:  altName,
+		Base:  normalizeSymbol(baseAltName),
+		Quote: normalizeSymbol(quoteAltName),
+	}
+	if ps.Base != "" && ps.Quote != "" {
+		ps.Symbol = ps.Base + "/" + ps.Quote
+	}
+	return ps
+}
+
+// aliases retourne toutes les formes acceptées pour désigner la paire, en majuscules :
+// XXBTZUSD, XBTUSD, XBT/USD, BTC/USD, BTC-USD, BTC_USD, BTCUSD...
+func (ps PairSymbol) aliases(wsName string) []string {
+	aliases := []string{ps.Name, ps.Pair, wsName}
+	bases := []string{ps.Base}
+	quotes := []string{ps.Quote}
+	// Accepter aussi les noms Kraken des actifs (XBT-USD)
+	if kBase, kQuote, ok := strings.Cut(wsName, "/"); ok {
+		bases = append(bases, kBase)
+		quotes = append(quotes, kQuote)
+	}
+	for _, base := range bases {
+		for _, quote := range quotes {
+			if base == "" || quote == "" {
+				continue
+			}
+			for _, sep := range []string{"/", "-", "_", ""} {
+				aliases = append(aliases, base+sep+quote)
+			}
+		}
+	}
+	for i, alias := range aliases {
+		aliases[i] = strings.ToUpper(alias)
+	}
+	return aliases
+}
+
+// indexPairSymbol ajoute toutes les formes d'une paire à un index. En cas de collision,
+// un nom exact (interne ou alternatif) l'emporte sur une forme dérivée.
+func indexPairSymbol(index map[string]PairSymbol, ps PairSymbol, wsName string) {
+	for _, alias := range ps.aliases(wsName) {
+		if _, exists := index[alias]; !exists || alias == strings.ToUpper(ps.Name) || alias == strings.ToUpper(ps.Pair) {
+			index[alias] = ps
+		}
+	}
+}
+
+// Durée pendant laquelle le catalogue des symboles chargé en mémoire est réutilisé
+const symbolCatalogTTL = 5 * time.Minute
+
+// Catalogue des symboles, construit à partir des tables pairs et assets :
+// traduit n'importe quelle forme de nom de paire vers le nom stocké et sa forme canonique.
+type SymbolCatalog struct {
+	DB *sql.DB
+
+	mu       sync.Mutex
+	byAlias  map[string]PairSymbol // Forme en majuscules -> paire
+	byPair   map[string]PairSymbol // Nom stocké -> paire
+	loadedAt time.Time
+}
+
+// NewSymbolCatalog crée un catalogue chargé à la demande depuis la base
+func NewSymbolCatalog(db *sql.DB) *SymbolCatalog {
+	return &SymbolCatalog{DB: db}
+}
+
+// load recharge le catalogue s'il est trop ancien
+func (c *SymbolCatalog) load(ctx context.Context) {
+	if c.byAlias != nil && time.Since(c.loadedAt) < symbolCatalogTTL {
+		return
+	}
+
+	rows, err := c.DB.QueryContext(ctx, `SELECT p.name, p.altname, COALESCE(p.wsname, ''),
+		COALESCE(b.altname, ''), COALESCE(q.altname, '')
+		FROM pairs p
+		LEFT JOIN assets b ON b.name = p.base
+		LEFT JOIN assets q ON q.name = p.quote`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	byAlias := make(map[string]PairSymbol)
+	byPair := make(map[string]PairSymbol)
+	for rows.Next() {
+		var name, altName, wsName, baseAltName, quoteAltName string
+		if err := rows.Scan(&name, &altName, &wsName, &baseAltName, &quoteAltName); err != nil {
+			return
+		}
+		ps := newPairSymbol(name, altName, wsName, baseAltName, quoteAltName)
+		byPair[altName] = ps
+		indexPairSymbol(byAlias, ps, wsName)
+	}
+	// Catalogue pas encore rafraîchi : réessayer au prochain appel
+	if rows.Err() != nil || len(byPair) == 0 {
+		return
+	}
+	c.byAlias = byAlias
+	c.byPair = byPair
+	c.loadedAt = time.Now()
+}
+
+// Resolve retrouve une paire à partir de n'importe laquelle de ses formes
+// (XXBTZUSD, XBTUSD, XBT/USD, BTC/USD, BTC-USD, sans tenir compte de la casse)
+func (c *SymbolCatalog) Resolve(ctx context.Context, input string) (PairSymbol, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load(ctx)
+	ps, ok := c.byAlias[strings.ToUpper(strings.TrimSpace(input))]
+	return ps, ok
+}
+
+// PairName retourne le nom stocké en base pour une forme quelconque de la paire.
+// Une paire absente du catalogue Kraken (archivée sur un autre exchange) est nommée
+// selon la même convention si elle est donnée sous la forme BTC/USDT ou BTC-USDT,
+// et retournée telle quelle sinon.
+func (c *SymbolCatalog) PairName(ctx context.Context, input string) string {
+	if ps, ok := c.Resolve(ctx, input); ok {
+		return ps.Pair
+	}
+	for _, sep := range []string{"/", "-"} {
+		if base, quote, ok := strings.Cut(input, sep); ok && base != "" && quote != "" {
+			return canonicalPairName(base, quote)
+		}
+	}
+	return input
+}
+
+// Symbol retourne la forme canonique (BTC/USD) d'une paire stockée, vide si elle est inconnue
+func (c *SymbolCatalog) Symbol(ctx context.Context, pair string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load(ctx)
+	return c.byPair[pair].Symbol
+}
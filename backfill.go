@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ------------------- Rattrapage de l'historique (OHLC) -------------------
+
+// Structure d'une bougie retournée par l'endpoint OHLC de Kraken
+type OHLCEntry struct {
+	Time   int64
+	Open   Decimal
+	High   Decimal
+	Low    Decimal
+	Close  Decimal
+	VWAP   Decimal
+	Volume Decimal
+	Count  int64
+}
+
+// UnmarshalJSON décode une bougie Kraken : [time, open, high, low, close, vwap, volume, count]
+func (e *OHLCEntry) UnmarshalJSON(data []byte) error {
+	var raw []interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) < 8 {
+		return fmt.Errorf("bougie OHLC incomplète: %s", data)
+	}
+
+	e.Time = int64(parseKrakenTime(raw[0]))
+	e.Open = decimalFromJSON(raw[1])
+	e.High = decimalFromJSON(raw[2])
+	e.Low = decimalFromJSON(raw[3])
+	e.Close = decimalFromJSON(raw[4])
+	e.VWAP = decimalFromJSON(raw[5])
+	e.Volume = decimalFromJSON(raw[6])
+	e.Count = int64(parseKrakenTime(raw[7]))
+	return nil
+}
+
+// GetOHLC récupère les bougies d'une paire depuis le curseur since.
+// Kraken ne renvoie que les 720 bougies les plus récentes ; last est le curseur à
+// utiliser pour la requête suivante.
+func (c *KrakenClient) GetOHLC(ctx context.Context, pair string, intervalMinutes int, since int64) ([]OHLCEntry, int64, error) {
+	params := url.Values{}
+	params.Set("pair", pair)
+	params.Set("interval", strconv.Itoa(intervalMinutes))
+	if since > 0 {
+		params.Set("since", strconv.FormatInt(since, 10))
+	}
+
+	var response struct {
+		Error  []string                   `json:"error"`
+		Result map[string]json.RawMessage `json:"result"`
+	}
+	if err := c.get(ctx, "/0/public/OHLC", params, &response); err != nil {
+		return nil, 0, err
+	}
+
+	var entries []OHLCEntry
+	var last int64
+	for key, raw := range response.Result {
+		if key == "last" {
+			if err := json.Unmarshal(raw, &last); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return nil, 0, err
+		}
+	}
+	return entries, last, nil
+}
+
+// InsertOHLCEntries enregistre des bougies de manière idempotente : une bougie déjà
+// présente (même exchange, paire, intervalle et date) est mise à jour au lieu d'être dupliquée.
+func InsertOHLCEntries(ctx context.Context, db *sql.DB, exchange, pair string, intervalMinutes int, entries []OHLCEntry) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO crypto_candles
+		(exchange, pair, interval_minutes, time, open, high, low, close, vwap, volume, trade_count)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(exchange, pair, interval_minutes, time) DO UPDATE SET
+			open=excluded.open,
+			high=excluded.high,
+			low=excluded.low,
+			close=excluded.close,
+			vwap=excluded.vwap,
+			volume=excluded.volume,
+			trade_count=excluded.trade_count;`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, e := range entries {
+		timestamp := time.Unix(e.Time, 0).UTC().Format(time.RFC3339)
+		if _, err := stmt.ExecContext(ctx, exchange, pair, intervalMinutes, timestamp,
+			e.Open, e.High, e.Low, e.Close, e.VWAP, e.Volume, e.Count); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// BackfillPair rapatrie l'historique OHLC d'une paire d'un exchange en suivant le
+// curseur since/last et retourne le nombre de bougies enregistrées.
+func BackfillPair(ctx context.Context, ex Exchange, db *sql.DB, pair PairMapping, intervalMinutes int, since int64) (int, error) {
+	total := 0
+	for {
+		entries, last, err := ex.GetOHLC(ctx, pair, intervalMinutes, since)
+		if err != nil {
+			return total, err
+		}
+		if len(entries) > 0 {
+			// Stocker avec le nom alternatif, comme les relevés du ticker
+			if err := InsertOHLCEntries(ctx, db, ex.Name(), pair.AltName, intervalMinutes, entries); err != nil {
+				return total, err
+			}
+			total += len(entries)
+		}
+
+		// Plus rien de nouveau : le curseur n'avance plus
+		if len(entries) == 0 || last <= since {
+			return total, nil
+		}
+		since = last
+	}
+}
+
+// Backfill rapatrie l'historique OHLC de plusieurs paires d'un exchange, jusqu'à l'annulation de ctx
+func Backfill(ctx context.Context, ex Exchange, db *sql.DB, pairs []PairMapping, intervalMinutes int, since int64) {
+	for _, pair := range pairs {
+		count, err := BackfillPair(ctx, ex, db, pair, intervalMinutes, since)
+		if err != nil {
+			log.Printf("Erreur lors du rattrapage de %s (%s): %v", pair.AltName, ex.Name(), err)
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		log.Printf("Rattrapage %s (%s): %d bougie(s) de %d minute(s) enregistrée(s)", pair.AltName, ex.Name(), count, intervalMinutes)
+	}
+}
+
+// GetStoredCandles lit les bougies rapatriées d'une paire sur un exchange
+// (from et to inclus, ignorés s'ils sont nuls)
+func GetStoredCandles(ctx context.Context, db *sql.DB, exchange, pair string, intervalMinutes int, from, to time.Time) ([]Candle, error) {
+	query := `SELECT time, open, high, low, close, volume, trade_count FROM crypto_candles
+		WHERE exchange = ? AND pair = ? AND interval_minutes = ?`
+	args := []interface{}{exchange, pair, intervalMinutes}
+	if !from.IsZero() {
+		query += " AND time >= ?"
+		args = append(args, from.UTC().Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		query += " AND time <= ?"
+		args = append(args, to.UTC().Format(time.RFC3339))
+	}
+	query += " ORDER BY time"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candles := []Candle{}
+	for rows.Next() {
+		var c Candle
+		if err := rows.Scan(&c.Time, &c.Open, &c.High, &c.Low, &c.Close, &c.Volume, &c.Ticks); err != nil {
+			return nil, err
+		}
+		candles = append(candles, c)
+	}
+	return candles, rows.Err()
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ------------------- Client de l'API publique Binance -------------------
+
+// Valeurs par défaut du client Binance
+const (
+	defaultBinanceBaseURL = "https://api.binance.com"
+	defaultBinanceRate    = 10.0 // Binance autorise 6000 points de poids par minute et par IP
+	defaultBinanceBurst   = 20.0
+)
+
+// Nombre de paires par requête ticker/24hr ; au-delà, tous les tickers sont demandés en une fois
+const binanceTickerBatchSize = 100
+
+// Nombre maximal de bougies par requête klines
+const binanceKlinesLimit = 1000
+
+// Intervalles des bougies Binance, indexés par durée en minutes
+var binanceIntervals = map[int]string{
+	1:     "1m",
+	5:     "5m",
+	15:    "15m",
+	30:    "30m",
+	60:    "1h",
+	240:   "4h",
+	1440:  "1d",
+	10080: "1w",
+}
+
+// Adaptateur de l'API publique REST de Binance vers l'interface Exchange.
+// L'URL de base peut pointer vers un serveur local (tests) ou un proxy.
+type BinanceClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	UserAgent  string
+	Limiter    *RateLimiter  // Limiteur de débit (nil : aucun)
+	RankingTTL time.Duration // Intervalle de réévaluation de la sélection des paires suivies
+	Universe   PairSelection // Politique de sélection des paires suivies
+
+	selection selectionCache // Paires suivies, réévaluées toutes les RankingTTL
+}
+
+// NewBinanceClient crée un client vers baseURL avec un délai maximal par requête
+func NewBinanceClient(baseURL string, timeout time.Duration) *BinanceClient {
+	return &BinanceClient{
+		BaseURL:    baseURL,
+		HTTPClient: &http.Client{Timeout: timeout},
+		UserAgent:  defaultKrakenUserAgent,
+		Limiter:    NewRateLimiter(RateLimit{Rate: defaultBinanceRate, Burst: defaultBinanceBurst}, nil),
+		RankingTTL: defaultRankingTTL,
+		Universe:   PairSelection{Top: defaultUniverseTop},
+	}
+}
+
+// get appelle un endpoint public (ex: "/api/v3/time") et décode la réponse JSON dans out.
+// Les erreurs de Binance sont de la forme {"code": -1121, "msg": "Invalid symbol."}.
+func (b *BinanceClient) get(ctx context.Context, path string, params url.Values, out interface{}) error {
+	endpoint := path[strings.LastIndex(path, "/")+1:]
+	if b.Limiter != nil {
+		if err := b.Limiter.Wait(ctx, endpoint); err != nil {
+			return err
+		}
+	}
+
+	requestURL := b.BaseURL + path
+	if len(params) > 0 {
+		requestURL += "?" + params.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	if b.UserAgent != "" {
+		req.Header.Set("User-Agent", b.UserAgent)
+	}
+
+	resp, err := b.HTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("binance %s: %v", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("binance %s: %v", endpoint, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// 429 : débit dépassé, 418 : IP bannie temporairement après des 429 répétés
+		if (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot) && b.Limiter != nil {
+			b.Limiter.RateLimited(endpoint)
+		}
+		var apiErr struct {
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Msg != "" {
+			return fmt.Errorf("binance %s: HTTP %d: %s (code %d)", endpoint, resp.StatusCode, apiErr.Msg, apiErr.Code)
+		}
+		return fmt.Errorf("binance %s: HTTP %d", endpoint, resp.StatusCode)
+	}
+	if b.Limiter != nil {
+		b.Limiter.Succeeded(endpoint)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("binance %s: réponse invalide: %v", endpoint, err)
+	}
+	return nil
+}
+
+func (b *BinanceClient) Name() string {
+	return binanceExchangeName
+}
+
+func (b *BinanceClient) ServerTime(ctx context.Context) (time.Time, error) {
+	var response struct {
+		ServerTime int64 `json:"serverTime"` // En millisecondes
+	}
+	if err := b.get(ctx, "/api/v3/time", nil, &response); err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(response.ServerTime).UTC(), nil
+}
+
+// GetExchangeInfo retourne toutes les paires négociables, nommées comme sur Kraken
+// (BTCUSDT devient XBTUSDT) pour être comparables entre exchanges
+func (b *BinanceClient) GetExchangeInfo(ctx context.Context) ([]PairMapping, error) {
+	var response struct {
+		Symbols []struct {
+			Symbol     string `json:"symbol"`
+			Status     string `json:"status"`
+			BaseAsset  string `json:"baseAsset"`
+			QuoteAsset string `json:"quoteAsset"`
+		} `json:"symbols"`
+	}
+	if err := b.get(ctx, "/api/v3/exchangeInfo", nil, &response); err != nil {
+		return nil, err
+	}
+
+	var pairs []PairMapping
+	for _, s := range response.Symbols {
+		if s.Status != "TRADING" {
+			continue
+		}
+		pairs = append(pairs, PairMapping{
+			InternalName: s.Symbol,
+			AltName:      canonicalPairName(s.BaseAsset, s.QuoteAsset),
+			WSName:       s.BaseAsset + "/" + s.QuoteAsset,
+			Base:         normalizeSymbol(s.BaseAsset),
+			Quote:        normalizeSymbol(s.QuoteAsset),
+		})
+	}
+	return pairs, nil
+}
+
+// ListPairs retourne les paires sélectionnées selon Universe, réévaluées toutes les RankingTTL
+func (b *BinanceClient) ListPairs(ctx context.Context) ([]PairMapping, error) {
+	return b.selection.get(binanceExchangeName, b.RankingTTL, b.Universe, func() ([]PairMapping, error) {
+		return b.GetExchangeInfo(ctx)
+	}, func(all []PairMapping) ([]PairRanking, error) {
+		ticks, err := b.GetTickers(ctx, all)
+		return marketFromTicks(all, ticks), err
+	})
+}
+
+// Ticker sur 24h glissantes retourné par l'endpoint ticker/24hr
+type binanceTicker struct {
+	Symbol           string  `json:"symbol"`
+	LastPrice        Decimal `json:"lastPrice"`
+	LastQty          Decimal `json:"lastQty"`
+	BidPrice         Decimal `json:"bidPrice"`
+	BidQty           Decimal `json:"bidQty"`
+	AskPrice         Decimal `json:"askPrice"`
+	AskQty           Decimal `json:"askQty"`
+	OpenPrice        Decimal `json:"openPrice"`
+	HighPrice        Decimal `json:"highPrice"`
+	LowPrice         Decimal `json:"lowPrice"`
+	Volume           Decimal `json:"volume"`
+	WeightedAvgPrice Decimal `json:"weightedAvgPrice"`
+	Count            int64   `json:"count"`
+}
+
+// tick convertit le ticker en relevé. Binance ne fournit que la fenêtre glissante
+// de 24h : les valeurs « du jour » (High, Low, VolumeToday...) restent vides.
+func (t *binanceTicker) tick(pair string) *Tick {
+	return &Tick{
+		Exchange:     binanceExchangeName,
+		Pair:         pair,
+		Ask:          t.AskPrice,
+		AskLotVolume: t.AskQty,
+		Bid:          t.BidPrice,
+		BidLotVolume: t.BidQty,
+		Last:         t.LastPrice,
+		LastVolume:   t.LastQty,
+		Open:         t.OpenPrice, // Ouverture de la fenêtre de 24h
+		Volume:       t.Volume,
+		High24h:      t.HighPrice,
+		Low24h:       t.LowPrice,
+		VWAP24h:      t.WeightedAvgPrice,
+		Trades24h:    Decimal(strconv.FormatInt(t.Count, 10)),
+	}
+}
+
+func (b *BinanceClient) GetTickers(ctx context.Context, pairs []PairMapping) (map[string]*Tick, error) {
+	bySymbol := make(map[string]string)
+	var symbols []string
+	for _, pair := range pairs {
+		bySymbol[pair.InternalName] = pair.AltName
+		symbols = append(symbols, pair.InternalName)
+	}
+
+	ticks := make(map[string]*Tick)
+	collect := func(params url.Values) error {
+		var tickers []binanceTicker
+		if err := b.get(ctx, "/api/v3/ticker/24hr", params, &tickers); err != nil {
+			return err
+		}
+		for i := range tickers {
+			if pair, ok := bySymbol[tickers[i].Symbol]; ok {
+				ticks[pair] = tickers[i].tick(pair)
+			}
+		}
+		return nil
+	}
+
+	// Beaucoup de paires (classement) : une seule requête pour tous les tickers
+	if len(symbols) > binanceTickerBatchSize {
+		return ticks, collect(nil)
+	}
+	if len(symbols) == 0 {
+		return ticks, nil
+	}
+	encoded, err := json.Marshal(symbols)
+	if err != nil {
+		return nil, err
+	}
+	return ticks, collect(url.Values{"symbols": {string(encoded)}})
+}
+
+// GetTicker interroge le meilleur bid/ask d'une paire (endpoint bookTicker, deux fois
+// moins coûteux en poids que ticker/24hr)
+func (b *BinanceClient) GetTicker(ctx context.Context, pair PairMapping) (*Tick, error) {
+	var response struct {
+		BidPrice Decimal `json:"bidPrice"`
+		BidQty   Decimal `json:"bidQty"`
+		AskPrice Decimal `json:"askPrice"`
+		AskQty   Decimal `json:"askQty"`
+	}
+	if err := b.get(ctx, "/api/v3/ticker/bookTicker", url.Values{"symbol": {pair.InternalName}}, &response); err != nil {
+		return nil, err
+	}
+	return &Tick{
+		Exchange:     binanceExchangeName,
+		Pair:         pair.AltName,
+		Ask:          response.AskPrice,
+		AskLotVolume: response.AskQty,
+		Bid:          response.BidPrice,
+		BidLotVolume: response.BidQty,
+	}, nil
+}
+
+// GetOHLC récupère au plus 1000 bougies depuis since. Une bougie Binance est de la forme
+// [openTime (ms), open, high, low, close, volume, closeTime, quoteVolume, trades, ...] ;
+// le VWAP n'est pas fourni.
+func (b *BinanceClient) GetOHLC(ctx context.Context, pair PairMapping, intervalMinutes int, since int64) ([]OHLCEntry, int64, error) {
+	interval, ok := binanceIntervals[intervalMinutes]
+	if !ok {
+		return nil, 0, fmt.Errorf("intervalle non pris en charge par Binance: %d minute(s)", intervalMinutes)
+	}
+	params := url.Values{}
+	params.Set("symbol", pair.InternalName)
+	params.Set("interval", interval)
+	params.Set("limit", strconv.Itoa(binanceKlinesLimit))
+	if since > 0 {
+		params.Set("startTime", strconv.FormatInt(since*1000, 10))
+	}
+
+	var klines [][]interface{}
+	if err := b.get(ctx, "/api/v3/klines", params, &klines); err != nil {
+		return nil, 0, err
+	}
+
+	var entries []OHLCEntry
+	last := since
+	for _, k := range klines {
+		if len(k) < 9 {
+			return nil, 0, fmt.Errorf("bougie Binance incomplète: %v", k)
+		}
+		entry := OHLCEntry{
+			Time:   int64(parseKrakenTime(k[0])) / 1000,
+			Open:   decimalFromJSON(k[1]),
+			High:   decimalFromJSON(k[2]),
+			Low:    decimalFromJSON(k[3]),
+			Close:  decimalFromJSON(k[4]),
+			Volume: decimalFromJSON(k[5]),
+			Count:  int64(parseKrakenTime(k[8])),
+		}
+		entries = append(entries, entry)
+		// Curseur suivant : juste après l'ouverture de la dernière bougie
+		last = entry.Time + 1
+	}
+	return entries, last, nil
+}
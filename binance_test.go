@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBinanceFixtureServer sert les réponses enregistrées de testdata/binance :
+// /api/v3/ticker/24hr est servi par ticker_24hr.json, et ainsi de suite.
+func newBinanceFixtureServer(t *testing.T, requests *[]*http.Request) *httptest.Server {
+	t.Helper()
+	return newFixtureServer(t, "binance", http.StatusBadRequest, func(path string) string {
+		return strings.ReplaceAll(strings.TrimPrefix(path, "/api/v3/"), "/", "_")
+	}, requests)
+}
+
+func newTestBinanceClient(server *httptest.Server) *BinanceClient {
+	client := NewBinanceClient(server.URL, 5*time.Second)
+	client.Limiter = nil
+	return client
+}
+
+func TestBinanceServerTime(t *testing.T) {
+	client := newTestBinanceClient(newBinanceFixtureServer(t, nil))
+
+	serverTime, err := client.ServerTime(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := serverTime.UnixMilli(); got != 1741944413123 {
+		t.Errorf("ServerTime = %d, attendu 1741944413123", got)
+	}
+}
+
+func TestBinanceGetExchangeInfo(t *testing.T) {
+	client := newTestBinanceClient(newBinanceFixtureServer(t, nil))
+
+	pairs, err := client.GetExchangeInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// LUNAUSDT n'est pas négociable ; les noms suivent la convention Kraken
+	want := []PairMapping{
+		{InternalName: "BTCUSDT", AltName: "XBTUSDT", WSName: "BTC/USDT", Base: "BTC", Quote: "USDT"},
+		{InternalName: "ETHUSDT", AltName: "ETHUSDT", WSName: "ETH/USDT", Base: "ETH", Quote: "USDT"},
+		{InternalName: "ETHBTC", AltName: "ETHXBT", WSName: "ETH/BTC", Base: "ETH", Quote: "BTC"},
+		{InternalName: "DOGEUSDT", AltName: "XDGUSDT", WSName: "DOGE/USDT", Base: "DOGE", Quote: "USDT"},
+	}
+	if len(pairs) != len(want) {
+		t.Fatalf("GetExchangeInfo = %+v, attendu %+v", pairs, want)
+	}
+	for i := range want {
+		if pairs[i] != want[i] {
+			t.Errorf("paire %d = %+v, attendu %+v", i, pairs[i], want[i])
+		}
+	}
+}
+
+func TestBinanceListPairsRanksByConvertedVolume(t *testing.T) {
+	client := newTestBinanceClient(newBinanceFixtureServer(t, nil))
+	client.Universe = PairSelection{Top: 3, Currency: "USDT"}
+
+	pairs, err := client.ListPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// DOGE a le plus gros volume en actif de base mais pas en USDT ; ETH/BTC est converti via BTC/USDT
+	if len(pairs) != 3 || pairs[0].AltName != "XBTUSDT" || pairs[1].AltName != "ETHUSDT" || pairs[2].AltName != "XDGUSDT" {
+		t.Fatalf("ListPairs = %+v, attendu XBTUSDT, ETHUSDT puis XDGUSDT", pairs)
+	}
+}
+
+func TestBinanceGetTickers(t *testing.T) {
+	var requests []*http.Request
+	client := newTestBinanceClient(newBinanceFixtureServer(t, &requests))
+	pairs := []PairMapping{
+		{InternalName: "BTCUSDT", AltName: "XBTUSDT"},
+		{InternalName: "ETHBTC", AltName: "ETHXBT"},
+		{InternalName: "SOLUSDT", AltName: "SOLUSDT"}, // Absente des réponses enregistrées
+	}
+
+	ticks, err := client.GetTickers(context.Background(), pairs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ticks) != 2 || ticks["ETHXBT"] == nil {
+		t.Fatalf("GetTickers = %v, attendu XBTUSDT et ETHXBT", ticks)
+	}
+	// Les paires suivies sont demandées en une requête
+	if got := requests[0].URL.Query().Get("symbols"); got != `["BTCUSDT","ETHBTC","SOLUSDT"]` {
+		t.Errorf("paramètre symbols = %s", got)
+	}
+
+	tick := ticks["XBTUSDT"]
+	want := Tick{
+		Exchange:     "binance",
+		Pair:         "XBTUSDT",
+		Ask:          "83912.02000000",
+		AskLotVolume: "4.29730000",
+		Bid:          "83912.01000000",
+		BidLotVolume: "3.51622000",
+		Last:         "83912.01000000",
+		LastVolume:   "0.00035000",
+		Open:         "83103.58000000",
+		Volume:       "19856.90384000",
+		High24h:      "84327.29000000",
+		Low24h:       "82018.25000000",
+		VWAP24h:      "83201.44126791",
+		Trades24h:    "3102587",
+	}
+	if *tick != want {
+		t.Errorf("relevé = %+v, attendu %+v", *tick, want)
+	}
+}
+
+func TestBinanceGetTicker(t *testing.T) {
+	var requests []*http.Request
+	client := newTestBinanceClient(newBinanceFixtureServer(t, &requests))
+
+	tick, err := client.GetTicker(context.Background(), PairMapping{InternalName: "BTCUSDT", AltName: "XBTUSDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Meilleurs prix du carnet seulement (bookTicker), sans les statistiques sur 24h
+	want := Tick{Exchange: "binance", Pair: "XBTUSDT", Bid: "83912.01000000", BidLotVolume: "3.51622000", Ask: "83912.02000000", AskLotVolume: "4.29730000"}
+	if *tick != want {
+		t.Errorf("GetTicker = %+v, attendu %+v", *tick, want)
+	}
+	if got := requests[0].URL.Query().Get("symbol"); requests[0].URL.Path != "/api/v3/ticker/bookTicker" || got != "BTCUSDT" {
+		t.Errorf("requête = %s", requests[0].URL)
+	}
+}
+
+func TestBinanceGetOHLC(t *testing.T) {
+	var requests []*http.Request
+	client := newTestBinanceClient(newBinanceFixtureServer(t, &requests))
+	pair := PairMapping{InternalName: "BTCUSDT", AltName: "XBTUSDT"}
+
+	entries, last, err := client.GetOHLC(context.Background(), pair, 60, 1741935600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].Time != 1741935600 || entries[2].Time != 1741942800 {
+		t.Fatalf("bougies = %+v", entries)
+	}
+	first := entries[0]
+	if first.Open != "83402.77000000" || first.High != "83650.00000000" || first.Low != "83311.18000000" ||
+		first.Close != "83512.62000000" || first.Volume != "288.06210000" || first.Count != 51042 || first.VWAP != "" {
+		t.Errorf("bougie = %+v", first)
+	}
+	if last != 1741942801 {
+		t.Errorf("curseur = %d, attendu 1741942801", last)
+	}
+
+	query := requests[0].URL.Query()
+	if query.Get("symbol") != "BTCUSDT" || query.Get("interval") != "1h" || query.Get("startTime") != "1741935600000" || query.Get("limit") != "1000" {
+		t.Errorf("paramètres = %v", query)
+	}
+
+	if _, _, err := client.GetOHLC(context.Background(), pair, 2, 0); err == nil {
+		t.Error("intervalle de 2 minutes accepté, attendu une erreur")
+	}
+}
+
+func TestBinanceErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/time") {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":-1121,"msg":"Invalid symbol."}`))
+	}))
+	defer server.Close()
+	client := NewBinanceClient(server.URL, time.Second)
+
+	// Message d'erreur de Binance repris dans l'erreur
+	if _, err := client.GetTickers(context.Background(), []PairMapping{{InternalName: "NOPE"}}); err == nil || !strings.Contains(err.Error(), "Invalid symbol. (code -1121)") {
+		t.Errorf("erreur = %v, attendu le message de Binance", err)
+	}
+	if _, err := client.GetTicker(context.Background(), PairMapping{InternalName: "NOPE"}); err == nil || err.Error() != "binance bookTicker: HTTP 400: Invalid symbol. (code -1121)" {
+		t.Errorf("erreur = %v, attendu le message de Binance", err)
+	}
+
+	// Un dépassement de débit réduit le débit du limiteur
+	if _, err := client.ServerTime(context.Background()); err == nil || !strings.Contains(err.Error(), "HTTP 429") {
+		t.Errorf("erreur = %v, attendu HTTP 429", err)
+	}
+	if stats := client.Limiter.Stats(); stats[0].RateLimitErrors != 1 || !stats[0].Throttled {
+		t.Errorf("limiteur après un 429 = %+v", stats[0])
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ------------------- Agrégation en bougies OHLC -------------------
+
+// Intervalles de bougies acceptés
+var candleIntervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+// parseCandleInterval convertit un intervalle ("1m", "5m", "15m", "1h", "1d") en durée
+func parseCandleInterval(value string) (time.Duration, error) {
+	interval, ok := candleIntervals[value]
+	if !ok {
+		return 0, fmt.Errorf("intervalle invalide: %q (attendu: 1m, 5m, 15m, 1h ou 1d)", value)
+	}
+	return interval, nil
+}
+
+// Structure représentant une bougie OHLC.
+// Les prix sont ceux du dernier trade (Last) des relevés de l'intervalle.
+// Le ticker ne donne que des volumes cumulés : Volume est la somme des hausses du
+// volume du jour (remis à zéro à minuit UTC) entre deux relevés consécutifs, attribuées
+// à la bougie du second. Il reste vide sans volume du jour (Binance, Coinbase).
+type Candle struct {
+	Time   string  `json:"time"` // Début de l'intervalle (RFC3339, UTC)
+	Open   Decimal `json:"open"`
+	High   Decimal `json:"high"`
+	Low    Decimal `json:"low"`
+	Close  Decimal `json:"close"`
+	Volume Decimal `json:"volume"`
+	Ticks  int     `json:"ticks"` // Nombre de relevés agrégés (de trades pour les bougies Kraken)
+}
+
+// GetCandles calcule les bougies OHLC d'une paire à partir des relevés archivés d'un exchange.
+// from et to sont inclus et ignorés s'ils sont nuls. Les relevés sont lus en flux,
+// seule la liste des bougies est gardée en mémoire.
+func GetCandles(ctx context.Context, db *sql.DB, exchange, pair string, interval time.Duration, from, to time.Time) ([]Candle, error) {
+	conditions := []string{"exchange = ?", "pair = ?"}
+	args := []interface{}{exchange, pair}
+	if !from.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, from.UTC().Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		conditions = append(conditions, "timestamp <= ?")
+		args = append(args, to.UTC().Format(time.RFC3339))
+	}
+
+	// Le relevé précédant la plage sert de référence au volume du premier relevé
+	var previous volumeCounter
+	if !from.IsZero() {
+		var today sql.NullString
+		var timestamp string
+		err := db.QueryRowContext(ctx,
+			"SELECT volume_today, timestamp FROM crypto_history WHERE exchange = ? AND pair = ? AND timestamp < ? ORDER BY timestamp DESC, id DESC LIMIT 1",
+			exchange, pair, from.UTC().Format(time.RFC3339),
+		).Scan(&today, &timestamp)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if t, parseErr := time.Parse(time.RFC3339, timestamp); err == nil && parseErr == nil {
+			previous = volumeCounter{Decimal(today.String), t}
+		}
+	}
+
+	rows, err := db.QueryContext(ctx,
+		"SELECT last_trade_price, volume_today, timestamp FROM crypto_history WHERE "+
+			strings.Join(conditions, " AND ")+" ORDER BY timestamp, id",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candles := []Candle{}
+	var candle *Candle
+	var currentStart time.Time
+
+	for rows.Next() {
+		var price, today Decimal
+		var timestamp string
+		if err := rows.Scan(&price, &today, &timestamp); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339, timestamp)
+		if err != nil {
+			// Relevé au format inattendu : ignoré plutôt que de fausser l'agrégation
+			continue
+		}
+		current := volumeCounter{today, t}
+		volume, ok := current.since(previous)
+		previous = current
+		if price == "" {
+			continue
+		}
+
+		start := t.UTC().Truncate(interval)
+		if candle == nil || !start.Equal(currentStart) {
+			candles = append(candles, Candle{
+				Time: start.Format(time.RFC3339),
+				Open: price,
+				High: price,
+				Low:  price,
+			})
+			candle = &candles[len(candles)-1]
+			currentStart = start
+		}
+
+		if price.Cmp(candle.High) > 0 {
+			candle.High = price
+		}
+		if price.Cmp(candle.Low) < 0 {
+			candle.Low = price
+		}
+		candle.Close = price
+		if ok {
+			candle.Volume = candle.Volume.Add(volume)
+		}
+		candle.Ticks++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return candles, nil
+}
+
+// Volume du jour relevé à un instant donné
+type volumeCounter struct {
+	Today Decimal
+	At    time.Time
+}
+
+// since retourne le volume échangé depuis le relevé previous. Le volume du jour
+// repart de zéro à minuit UTC : après un changement de jour (ou une baisse du compteur),
+// tout le volume du jour est compté. false si l'un des deux relevés n'a pas de volume.
+func (c volumeCounter) since(previous volumeCounter) (Decimal, bool) {
+	if c.Today == "" || previous.Today == "" {
+		return "", false
+	}
+	if !c.At.UTC().Truncate(24*time.Hour).Equal(previous.At.UTC().Truncate(24*time.Hour)) || c.Today.Cmp(previous.Today) < 0 {
+		return c.Today, true
+	}
+	return c.Today.Sub(previous.Today), true
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetCandlesVolume(t *testing.T) {
+	db := InitDB(filepath.Join(t.TempDir(), "crypto.db"))
+	defer db.Close()
+	ctx := context.Background()
+
+	// Volume du jour cumulé, remis à zéro à minuit UTC
+	for _, tick := range []Tick{
+		{Timestamp: "2025-01-01T23:58:00Z", Last: "100", VolumeToday: "10"},
+		{Timestamp: "2025-01-01T23:59:00Z", Last: "101", VolumeToday: "12.5"},
+		{Timestamp: "2025-01-01T23:59:30Z", Last: "102", VolumeToday: "15"},
+		{Timestamp: "2025-01-02T00:00:30Z", Last: "103", VolumeToday: "1"},
+		{Timestamp: "2025-01-02T00:01:10Z", Last: "104", VolumeToday: "4.25"},
+	} {
+		tick.Exchange, tick.Pair = "kraken", "XBTUSD"
+		InsertCryptoData(ctx, db, &tick)
+	}
+	// Sans volume du jour (Binance), le volume des bougies reste vide
+	InsertCryptoData(ctx, db, &Tick{Exchange: "binance", Pair: "XBTUSD", Last: "100", Volume: "1000", Timestamp: "2025-01-01T23:58:00Z"})
+	InsertCryptoData(ctx, db, &Tick{Exchange: "binance", Pair: "XBTUSD", Last: "100", Volume: "1010", Timestamp: "2025-01-01T23:59:00Z"})
+
+	volumes := func(exchange string, from time.Time) map[string]Decimal {
+		candles, err := GetCandles(ctx, db, exchange, "XBTUSD", time.Minute, from, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]Decimal{}
+		for _, c := range candles {
+			got[c.Time[11:16]] = c.Volume
+		}
+		return got
+	}
+
+	want := map[string]Decimal{"23:58": "", "23:59": "5.0", "00:00": "1", "00:01": "3.25"}
+	got := volumes("kraken", time.Time{})
+	for minute, volume := range want {
+		if got[minute] != volume {
+			t.Errorf("bougie %s: volume %q, attendu %q", minute, got[minute], volume)
+		}
+	}
+
+	// Le relevé précédant la plage sert de référence au premier relevé
+	if got := volumes("kraken", time.Date(2025, 1, 1, 23, 59, 0, 0, time.UTC)); len(got) != 3 || got["23:59"] != "5.0" {
+		t.Errorf("volumes depuis 23:59 = %v", got)
+	}
+
+	for minute, volume := range volumes("binance", time.Time{}) {
+		if volume != "" {
+			t.Errorf("bougie binance %s: volume %q, attendu vide", minute, volume)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/LouisVannobel/crypto-archive/krakenfake"
+)
+
+// ------------------- Commandes en ligne de commande -------------------
+
+// Usage affiché lorsque la commande est inconnue
+const commandUsage = `Usage: crypto-archive [commande] [options]
+
+Sans commande, lance l'archivage et le serveur HTTP.
+
+Options communes à serve et backfill :
+  -kraken-url URL      URL de base de l'API Kraken (défaut https://api.kraken.com)
+  -kraken-timeout 15s  Délai maximal d'une requête
+  -user-agent UA       User-Agent envoyé à l'API
+  -ranking-ttl 1h      Intervalle de réévaluation de la sélection des paires suivies
+  -rate-limit 1:10     Limite globale des appels (requêtes/s:réserve)
+  -endpoint-rate-limits OHLC=0.5:2,...
+                       Limites supplémentaires par endpoint
+  -kraken-retries 4    Réessais d'un appel en erreur temporaire
+  -record-dir DIR      Enregistre les réponses brutes de l'API Kraken dans DIR
+
+Sélection des paires suivies (serve, backfill et replay) :
+  -pairs P1,BTC/*      Paires toujours suivies (noms ou motifs glob)
+  -exclude USDT/*      Paires jamais suivies (noms ou motifs glob)
+  -quotes USD,EUR      Devises de cotation des paires classées par volume
+  -top 20              Paires au plus fort volume (converti en -ranking-currency)
+                       ajoutées à la sélection (0 par défaut avec -pairs)
+  -ranking-currency USD
+                       Devise dans laquelle les volumes sont convertis pour le classement
+
+Exchanges archivés (serve et backfill) :
+  -exchanges kraken,binance,coinbase
+                       Exchanges interrogés (défaut kraken) ; la sélection des paires
+                       s'applique à chacun
+  -binance-url URL     URL de base de l'API Binance (défaut https://api.binance.com)
+  -coinbase-url URL    URL de base de l'API Coinbase Exchange
+                       (défaut https://api.exchange.coinbase.com)
+
+Commandes:
+  serve [-trades] [-trades-interval 1m] [-trades-pairs P1,P2]
+        [-depth] [-depth-interval 1m] [-depth-levels 10] [-depth-pairs P1,P2]
+        [-ws] [-ws-url URL] [-ws-pairs P1,P2] [-ws-channels ticker,trade,book] [-ws-flush-interval 10s]
+        Lance l'archivage et le serveur HTTP (commande par défaut) ;
+        -trades active la collecte des trades individuels,
+        -depth celle des instantanés du carnet d'ordres,
+        -ws remplace l'interrogation du ticker Kraken par l'API WebSocket
+  reset [-pair PAIRE] [-from DATE] [-to DATE]
+        Supprime les relevés archivés (toute la base si aucun filtre)
+  migrate [status|up]
+        Affiche la version du schéma et les migrations en attente (status, par défaut)
+        ou applique les migrations en attente (up)
+  backfill [-interval 1h] [-since DATE]
+        Rapatrie l'historique OHLC des exchanges pour les paires sélectionnées
+        (par défaut les 20 paires au plus fort volume)
+  replay -dir DIR [-speed 60] [-db data/replay.db]
+        Rejoue les réponses Kraken enregistrées avec -record-dir à travers le cycle
+        d'archivage, -speed fois plus vite qu'en réalité (0 : sans attente) ;
+        les relevés sont datés de leur enregistrement
+  fake-kraken [-addr :8081]
+        Lance un faux serveur de l'API publique Kraken (développement hors ligne) ;
+        à utiliser avec -kraken-url http://localhost:8081
+`
+
+// runCommand exécute la sous-commande demandée
+func runCommand(name string, args []string) error {
+	switch name {
+	case "serve":
+		return runServe(args)
+	case "reset":
+		return runReset(args)
+	case "migrate":
+		return runMigrate(args)
+	case "backfill":
+		return runBackfill(args)
+	case "replay":
+		return runReplay(args)
+	case "fake-kraken":
+		return runFakeKraken(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(commandUsage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, commandUsage)
+		return fmt.Errorf("commande inconnue: %s", name)
+	}
+}
+
+// runReset vide la base, éventuellement pour une paire ou une plage de temps
+func runReset(args []string) error {
+	fs := flag.NewFlagSet("reset", flag.ContinueOnError)
+	pair := fs.String("pair", "", "Ne supprimer que les relevés de cette paire (ex: XBTUSD)")
+	from := fs.String("from", "", "Début de la plage à supprimer (RFC3339, date YYYY-MM-DD ou timestamp Unix)")
+	to := fs.String("to", "", "Fin de la plage à supprimer (RFC3339, date YYYY-MM-DD ou timestamp Unix)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	filter := ResetFilter{Pair: *pair}
+	var err error
+	if *from != "" {
+		if filter.From, err = parseTimeParam(*from); err != nil {
+			return fmt.Errorf("option -from invalide: %v", err)
+		}
+	}
+	if *to != "" {
+		if filter.To, err = parseTimeParam(*to); err != nil {
+			return fmt.Errorf("option -to invalide: %v", err)
+		}
+	}
+
+	db := openDatabase()
+	defer db.Close()
+
+	deleted, err := CleanDB(context.Background(), db, filter)
+	if err != nil {
+		return fmt.Errorf("erreur lors du nettoyage de la base: %v", err)
+	}
+	log.Printf("Base nettoyée avec succès: %d relevé(s) supprimé(s).", deleted)
+	return nil
+}
+
+// runMigrate affiche l'état des migrations ou les applique
+func runMigrate(args []string) error {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "status":
+		// Consultation en lecture seule : la base n'est ni créée ni migrée
+		if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+			migrations, err := loadMigrations()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Base absente (%s), version du schéma: 0\n", dbPath)
+			fmt.Printf("Migrations en attente (%d):\n", len(migrations))
+			for _, m := range migrations {
+				fmt.Printf("- %s\n", m.Name)
+			}
+			return nil
+		}
+		db, err := sql.Open("sqlite3", "file:"+dbPath+"?mode=ro")
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		version, err := SchemaVersion(db)
+		if err != nil {
+			return err
+		}
+		pending, err := PendingMigrations(db)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Version du schéma: %d\n", version)
+		if len(pending) == 0 {
+			fmt.Println("Aucune migration en attente.")
+			return nil
+		}
+		fmt.Printf("Migrations en attente (%d):\n", len(pending))
+		for _, m := range pending {
+			fmt.Printf("- %s\n", m.Name)
+		}
+		return nil
+
+	case "up":
+		db := openDatabase()
+		defer db.Close()
+
+		version, err := SchemaVersion(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Schéma à jour, version %d\n", version)
+		return nil
+
+	default:
+		return fmt.Errorf("action migrate inconnue: %s (attendu: status ou up)", action)
+	}
+}
+
+// runBackfill rapatrie l'historique OHLC des exchanges sélectionnés dans la base
+func runBackfill(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	interval := fs.String("interval", "1h", "Intervalle des bougies (1m, 5m, 15m, 1h, 1d)")
+	since := fs.String("since", "", "Date de début (RFC3339, date YYYY-MM-DD ou timestamp Unix)")
+	newKrakenClient := addKrakenFlags(fs)
+	newSelection := addUniverseFlags(fs)
+	newExchanges := addExchangeFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := newKrakenClient()
+	if err != nil {
+		return err
+	}
+	client.Universe = newSelection()
+	exchanges, err := newExchanges(client)
+	if err != nil {
+		return err
+	}
+
+	duration, err := parseCandleInterval(*interval)
+	if err != nil {
+		return err
+	}
+	var sinceUnix int64
+	if *since != "" {
+		t, err := parseTimeParam(*since)
+		if err != nil {
+			return fmt.Errorf("option -since invalide: %v", err)
+		}
+		sinceUnix = t.Unix()
+	}
+
+	// Ctrl+C interrompt le rattrapage ; les bougies déjà enregistrées sont conservées
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db := openDatabase()
+	defer db.Close()
+
+	for _, ex := range exchanges {
+		pairs, err := ex.ListPairs(ctx)
+		if err != nil {
+			return fmt.Errorf("erreur récupération des paires (%s): %v", ex.Name(), err)
+		}
+		Backfill(ctx, ex, db, pairs, int(duration.Minutes()), sinceUnix)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// runReplay rejoue des réponses Kraken enregistrées dans une base dédiée
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	dir := fs.String("dir", "", "Dossier des réponses enregistrées avec -record-dir")
+	speed := fs.Float64("speed", 60, "Facteur d'accélération du rejeu (0 : sans attente entre les cycles)")
+	replayDB := fs.String("db", "data/replay.db", "Base SQLite alimentée par le rejeu")
+	newSelection := addUniverseFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return fmt.Errorf("option -dir requise")
+	}
+	if *speed < 0 {
+		return fmt.Errorf("option -speed invalide: %g", *speed)
+	}
+
+	replayer, err := LoadRecordings(*dir)
+	if err != nil {
+		return err
+	}
+	log.Printf("%d réponse(s) Kraken à rejouer depuis %s", replayer.Remaining(), *dir)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Le client n'appelle jamais l'API : son URL ne sert qu'à construire les requêtes rejouées
+	client := NewKrakenClient(defaultKrakenBaseURL, defaultKrakenTimeout)
+	client.Universe = newSelection()
+
+	ensureDataDir()
+	db := InitDB(*replayDB)
+	defer db.Close()
+
+	ReplayArchive(ctx, replayer, client, db, *speed)
+	return nil
+}
+
+// runFakeKraken sert le faux Kraken de krakenfake jusqu'à Ctrl+C
+func runFakeKraken(args []string) error {
+	fs := flag.NewFlagSet("fake-kraken", flag.ContinueOnError)
+	addr := fs.String("addr", ":8081", "Adresse d'écoute du faux serveur Kraken")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fake := krakenfake.New()
+	server := &http.Server{Addr: *addr, Handler: fake}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Faux serveur Kraken sur %s (paires: %s)", *addr, strings.Join(fake.Pairs(), ", "))
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// parseTimeParam accepte un temps au format RFC3339, une date YYYY-MM-DD ou un timestamp Unix
+func parseTimeParam(value string) (time.Time, error) {
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0).UTC(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("format de date non reconnu: %q", value)
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ------------------- Client de l'API publique Coinbase Exchange -------------------
+
+// Valeurs par défaut du client Coinbase
+const (
+	defaultCoinbaseBaseURL = "https://api.exchange.coinbase.com"
+	defaultCoinbaseRate    = 8.0 // Coinbase autorise 10 requêtes publiques par seconde et par IP
+	defaultCoinbaseBurst   = 15.0
+)
+
+// Nombre maximal de bougies par requête candles
+const coinbaseCandlesLimit = 300
+
+// Granularités des bougies Coinbase (en secondes), indexées par durée en minutes
+var coinbaseGranularities = map[int]int{
+	1:    60,
+	5:    300,
+	15:   900,
+	60:   3600,
+	360:  21600,
+	1440: 86400,
+}
+
+// Adaptateur de l'API publique REST de Coinbase Exchange vers l'interface Exchange.
+// L'URL de base peut pointer vers un serveur local (tests) ou un proxy.
+type CoinbaseClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	UserAgent  string
+	Limiter    *RateLimiter  // Limiteur de débit (nil : aucun)
+	RankingTTL time.Duration // Intervalle de réévaluation de la sélection des paires suivies
+	Universe   PairSelection // Politique de sélection des paires suivies
+
+	selection selectionCache // Paires suivies, réévaluées toutes les RankingTTL
+}
+
+// NewCoinbaseClient crée un client vers baseURL avec un délai maximal par requête
+func NewCoinbaseClient(baseURL string, timeout time.Duration) *CoinbaseClient {
+	return &CoinbaseClient{
+		BaseURL:    baseURL,
+		HTTPClient: &http.Client{Timeout: timeout},
+		UserAgent:  defaultKrakenUserAgent,
+		Limiter:    NewRateLimiter(RateLimit{Rate: defaultCoinbaseRate, Burst: defaultCoinbaseBurst}, nil),
+		RankingTTL: defaultRankingTTL,
+		Universe:   PairSelection{Top: defaultUniverseTop},
+	}
+}
+
+// get appelle un endpoint public (ex: "/products/BTC-USD/ticker") et décode la réponse JSON
+// dans out. Les erreurs de Coinbase sont de la forme {"message": "NotFound"}.
+// endpoint nomme la requête pour le limiteur de débit et les messages d'erreur.
+func (c *CoinbaseClient) get(ctx context.Context, endpoint, path string, params url.Values, out interface{}) error {
+	if c.Limiter != nil {
+		if err := c.Limiter.Wait(ctx, endpoint); err != nil {
+			return err
+		}
+	}
+
+	requestURL := c.BaseURL + path
+	if len(params) > 0 {
+		requestURL += "?" + params.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	// Coinbase refuse les requêtes sans User-Agent
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("coinbase %s: %v", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("coinbase %s: %v", endpoint, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusTooManyRequests && c.Limiter != nil {
+			c.Limiter.RateLimited(endpoint)
+		}
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("coinbase %s: HTTP %d: %s", endpoint, resp.StatusCode, apiErr.Message)
+		}
+		return fmt.Errorf("coinbase %s: HTTP %d", endpoint, resp.StatusCode)
+	}
+	if c.Limiter != nil {
+		c.Limiter.Succeeded(endpoint)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("coinbase %s: réponse invalide: %v", endpoint, err)
+	}
+	return nil
+}
+
+func (c *CoinbaseClient) Name() string {
+	return coinbaseExchangeName
+}
+
+func (c *CoinbaseClient) ServerTime(ctx context.Context) (time.Time, error) {
+	var response struct {
+		ISO   string  `json:"iso"`
+		Epoch float64 `json:"epoch"`
+	}
+	if err := c.get(ctx, "time", "/time", nil, &response); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(response.Epoch*float64(time.Second))).UTC(), nil
+}
+
+// GetProducts retourne toutes les paires négociables, nommées comme sur Kraken
+// (BTC-USD devient XBTUSD) pour être comparables entre exchanges
+func (c *CoinbaseClient) GetProducts(ctx context.Context) ([]PairMapping, error) {
+	var products []struct {
+		ID              string `json:"id"`
+		BaseCurrency    string `json:"base_currency"`
+		QuoteCurrency   string `json:"quote_currency"`
+		Status          string `json:"status"`
+		TradingDisabled bool   `json:"trading_disabled"`
+	}
+	if err := c.get(ctx, "products", "/products", nil, &products); err != nil {
+		return nil, err
+	}
+
+	var pairs []PairMapping
+	for _, p := range products {
+		if p.Status != "online" || p.TradingDisabled {
+			continue
+		}
+		pairs = append(pairs, PairMapping{
+			InternalName: p.ID,
+			AltName:      canonicalPairName(p.BaseCurrency, p.QuoteCurrency),
+			WSName:       p.BaseCurrency + "/" + p.QuoteCurrency,
+			Base:         normalizeSymbol(p.BaseCurrency),
+			Quote:        normalizeSymbol(p.QuoteCurrency),
+		})
+	}
+	// Ordre stable : l'API ne garantit pas l'ordre des produits
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].AltName < pairs[j].AltName
+	})
+	return pairs, nil
+}
+
+// ListPairs retourne les paires sélectionnées selon Universe, réévaluées toutes les RankingTTL
+func (c *CoinbaseClient) ListPairs(ctx context.Context) ([]PairMapping, error) {
+	return c.selection.get(coinbaseExchangeName, c.RankingTTL, c.Universe, func() ([]PairMapping, error) {
+		return c.GetProducts(ctx)
+	}, func(all []PairMapping) ([]PairRanking, error) {
+		return c.fetchMarket(ctx, all)
+	})
+}
+
+// fetchMarket récupère les statistiques sur 24h de tous les produits en une requête
+// (endpoint /products/stats) pour le classement par volume. Interroger chaque produit
+// séparément prendrait plusieurs minutes pour les centaines de produits de Coinbase.
+func (c *CoinbaseClient) fetchMarket(ctx context.Context, pairs []PairMapping) ([]PairRanking, error) {
+	var response map[string]struct {
+		Stats24h coinbaseStats `json:"stats_24hour"`
+	}
+	if err := c.get(ctx, "stats", "/products/stats", nil, &response); err != nil {
+		return nil, err
+	}
+
+	ticks := make(map[string]*Tick)
+	for _, pair := range pairs {
+		if stats, ok := response[pair.InternalName]; ok {
+			ticks[pair.AltName] = &Tick{Last: stats.Stats24h.Last, Volume: stats.Stats24h.Volume}
+		}
+	}
+	return marketFromTicks(pairs, ticks), nil
+}
+
+// Dernier trade et meilleurs prix retournés par l'endpoint ticker d'un produit
+type coinbaseTicker struct {
+	Ask    Decimal `json:"ask"`
+	Bid    Decimal `json:"bid"`
+	Price  Decimal `json:"price"` // Dernier trade
+	Size   Decimal `json:"size"`  // Volume du dernier trade
+	Volume Decimal `json:"volume"`
+}
+
+// Statistiques sur 24h glissantes retournées par l'endpoint stats d'un produit
+type coinbaseStats struct {
+	Open   Decimal `json:"open"`
+	High   Decimal `json:"high"`
+	Low    Decimal `json:"low"`
+	Last   Decimal `json:"last"`
+	Volume Decimal `json:"volume"`
+}
+
+// tick combine le ticker et les statistiques d'un produit. Comme pour Binance, seules
+// les valeurs sur 24h glissantes sont disponibles ; Coinbase ne fournit ni VWAP, ni
+// nombre de trades, ni volume au meilleur prix.
+func (t *coinbaseTicker) tick(pair string, stats *coinbaseStats) *Tick {
+	tick := &Tick{
+		Exchange:   coinbaseExchangeName,
+		Pair:       pair,
+		Ask:        t.Ask,
+		Bid:        t.Bid,
+		Last:       t.Price,
+		LastVolume: t.Size,
+		Volume:     t.Volume,
+	}
+	if stats != nil {
+		tick.Open = stats.Open // Ouverture de la fenêtre de 24h
+		tick.High24h = stats.High
+		tick.Low24h = stats.Low
+	}
+	return tick
+}
+
+// GetTickers interroge le ticker et les statistiques sur 24h de chaque paire
+// (deux requêtes par paire : Coinbase n'a pas d'endpoint groupé documenté)
+func (c *CoinbaseClient) GetTickers(ctx context.Context, pairs []PairMapping) (map[string]*Tick, error) {
+	ticks := make(map[string]*Tick)
+	var lastErr error
+	for _, pair := range pairs {
+		if ctx.Err() != nil {
+			return ticks, ctx.Err()
+		}
+		path := "/products/" + url.PathEscape(pair.InternalName)
+
+		var ticker coinbaseTicker
+		if err := c.get(ctx, "ticker", path+"/ticker", nil, &ticker); err != nil {
+			lastErr = err
+			continue
+		}
+		// Sans statistiques, le relevé garde le ticker
+		var stats coinbaseStats
+		statsPtr := &stats
+		if err := c.get(ctx, "stats", path+"/stats", nil, &stats); err != nil {
+			lastErr = err
+			statsPtr = nil
+		}
+		ticks[pair.AltName] = ticker.tick(pair.AltName, statsPtr)
+	}
+	return ticks, lastErr
+}
+
+// GetTicker interroge le ticker d'une paire, sans ses statistiques sur 24h
+func (c *CoinbaseClient) GetTicker(ctx context.Context, pair PairMapping) (*Tick, error) {
+	var ticker coinbaseTicker
+	if err := c.get(ctx, "ticker", "/products/"+url.PathEscape(pair.InternalName)+"/ticker", nil, &ticker); err != nil {
+		return nil, err
+	}
+	return ticker.tick(pair.AltName, nil), nil
+}
+
+// GetOHLC récupère au plus 300 bougies depuis since. Une bougie Coinbase est de la forme
+// [time, low, high, open, close, volume], de la plus récente à la plus ancienne ;
+// ni le VWAP ni le nombre de trades ne sont fournis. Les fenêtres sans bougie (paire
+// pas encore listée) sont sautées jusqu'à l'heure courante.
+func (c *CoinbaseClient) GetOHLC(ctx context.Context, pair PairMapping, intervalMinutes int, since int64) ([]OHLCEntry, int64, error) {
+	granularity, ok := coinbaseGranularities[intervalMinutes]
+	if !ok {
+		return nil, 0, fmt.Errorf("intervalle non pris en charge par Coinbase: %d minute(s)", intervalMinutes)
+	}
+	path := "/products/" + url.PathEscape(pair.InternalName) + "/candles"
+	window := int64(granularity * coinbaseCandlesLimit)
+	now := time.Now().Unix()
+
+	start := since
+	for {
+		params := url.Values{}
+		params.Set("granularity", strconv.Itoa(granularity))
+		if start > 0 {
+			end := start + window
+			params.Set("start", time.Unix(start, 0).UTC().Format(time.RFC3339))
+			params.Set("end", time.Unix(end, 0).UTC().Format(time.RFC3339))
+		}
+
+		var candles [][]json.Number
+		if err := c.get(ctx, "candles", path, params, &candles); err != nil {
+			return nil, 0, err
+		}
+
+		var entries []OHLCEntry
+		for _, k := range candles {
+			if len(k) < 6 {
+				return nil, 0, fmt.Errorf("bougie Coinbase incomplète: %v", k)
+			}
+			t, err := k[0].Int64()
+			if err != nil {
+				return nil, 0, fmt.Errorf("bougie Coinbase invalide: %v", k)
+			}
+			entries = append(entries, OHLCEntry{
+				Time:   t,
+				Low:    decimalFromJSON(k[1]),
+				High:   decimalFromJSON(k[2]),
+				Open:   decimalFromJSON(k[3]),
+				Close:  decimalFromJSON(k[4]),
+				Volume: decimalFromJSON(k[5]),
+			})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Time < entries[j].Time
+		})
+
+		if len(entries) > 0 {
+			// Curseur suivant : juste après l'ouverture de la dernière bougie
+			return entries, entries[len(entries)-1].Time + 1, nil
+		}
+		if start <= 0 || start+window >= now {
+			return nil, since, nil
+		}
+		start += window
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newCoinbaseFixtureServer sert les réponses enregistrées de testdata/coinbase :
+// /products/BTC-USD/ticker est servi par ticker_BTC-USD.json, et ainsi de suite.
+func newCoinbaseFixtureServer(t *testing.T, requests *[]*http.Request) *httptest.Server {
+	t.Helper()
+	return newFixtureServer(t, "coinbase", http.StatusNotFound, func(path string) string {
+		switch parts := strings.Split(strings.Trim(path, "/"), "/"); {
+		case len(parts) == 1:
+			return parts[0]
+		case len(parts) == 2 && parts[1] == "stats":
+			return "products_stats"
+		case len(parts) == 3:
+			return parts[2] + "_" + parts[1]
+		}
+		return ""
+	}, requests)
+}
+
+func newTestCoinbaseClient(server *httptest.Server) *CoinbaseClient {
+	client := NewCoinbaseClient(server.URL, 5*time.Second)
+	client.Limiter = nil
+	return client
+}
+
+func TestCoinbaseServerTime(t *testing.T) {
+	client := newTestCoinbaseClient(newCoinbaseFixtureServer(t, nil))
+
+	serverTime, err := client.ServerTime(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := serverTime.Unix(); got != 1741944413 {
+		t.Errorf("ServerTime = %d, attendu 1741944413", got)
+	}
+}
+
+func TestCoinbaseGetProducts(t *testing.T) {
+	client := newTestCoinbaseClient(newCoinbaseFixtureServer(t, nil))
+
+	pairs, err := client.GetProducts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// RAI-USD est retiré de la cote ; les noms suivent la convention Kraken
+	want := []PairMapping{
+		{InternalName: "ETH-USD", AltName: "ETHUSD", WSName: "ETH/USD", Base: "ETH", Quote: "USD"},
+		{InternalName: "ETH-BTC", AltName: "ETHXBT", WSName: "ETH/BTC", Base: "ETH", Quote: "BTC"},
+		{InternalName: "BTC-USD", AltName: "XBTUSD", WSName: "BTC/USD", Base: "BTC", Quote: "USD"},
+		{InternalName: "DOGE-USD", AltName: "XDGUSD", WSName: "DOGE/USD", Base: "DOGE", Quote: "USD"},
+	}
+	if len(pairs) != len(want) {
+		t.Fatalf("GetProducts = %+v, attendu %+v", pairs, want)
+	}
+	for i := range want {
+		if pairs[i] != want[i] {
+			t.Errorf("paire %d = %+v, attendu %+v", i, pairs[i], want[i])
+		}
+	}
+}
+
+func TestCoinbaseListPairsRanksByConvertedVolume(t *testing.T) {
+	client := newTestCoinbaseClient(newCoinbaseFixtureServer(t, nil))
+	client.Universe = PairSelection{Top: 2, Currency: "USD"}
+
+	pairs, err := client.ListPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// DOGE a le plus gros volume en actif de base mais pas en dollars
+	if len(pairs) != 2 || pairs[0].AltName != "XBTUSD" || pairs[1].AltName != "ETHUSD" {
+		t.Fatalf("ListPairs = %+v, attendu XBTUSD puis ETHUSD", pairs)
+	}
+}
+
+func TestCoinbaseGetTickers(t *testing.T) {
+	client := newTestCoinbaseClient(newCoinbaseFixtureServer(t, nil))
+	pairs := []PairMapping{
+		{InternalName: "BTC-USD", AltName: "XBTUSD"},
+		{InternalName: "SOL-USD", AltName: "SOLUSD"}, // Absente des réponses enregistrées
+	}
+
+	ticks, err := client.GetTickers(context.Background(), pairs)
+	if err == nil || !strings.Contains(err.Error(), "NotFound") {
+		t.Errorf("erreur = %v, attendu le message NotFound de Coinbase", err)
+	}
+	if len(ticks) != 1 {
+		t.Fatalf("GetTickers = %v, attendu seulement XBTUSD", ticks)
+	}
+
+	tick := ticks["XBTUSD"]
+	want := Tick{
+		Exchange:   "coinbase",
+		Pair:       "XBTUSD",
+		Ask:        "83912.02",
+		Bid:        "83912.01",
+		Last:       "83912.01",
+		LastVolume: "0.00035481",
+		Volume:     "9856.90384619",
+		Open:       "83103.58",
+		High24h:    "84327.29",
+		Low24h:     "82018.25",
+	}
+	if *tick != want {
+		t.Errorf("relevé = %+v, attendu %+v", *tick, want)
+	}
+}
+
+func TestCoinbaseGetOHLC(t *testing.T) {
+	var requests []*http.Request
+	client := newTestCoinbaseClient(newCoinbaseFixtureServer(t, &requests))
+	pair := PairMapping{InternalName: "BTC-USD", AltName: "XBTUSD"}
+
+	entries, last, err := client.GetOHLC(context.Background(), pair, 60, 1741935600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("%d bougies, attendu 3", len(entries))
+	}
+	// Coinbase renvoie les bougies de la plus récente à la plus ancienne
+	if entries[0].Time != 1741935600 || entries[2].Time != 1741942800 {
+		t.Errorf("bougies non triées: %+v", entries)
+	}
+	first := entries[0]
+	if first.Open != "83402.77" || first.High != "83650" || first.Low != "83311.18" || first.Close != "83512.62" || first.Volume != "288.06210532" {
+		t.Errorf("bougie = %+v", first)
+	}
+	if last != 1741942801 {
+		t.Errorf("curseur = %d, attendu 1741942801", last)
+	}
+
+	query := requests[0].URL.Query()
+	if query.Get("granularity") != "3600" || query.Get("start") != "2025-03-14T07:00:00Z" || query.Get("end") != "2025-03-26T19:00:00Z" {
+		t.Errorf("paramètres = %v", query)
+	}
+
+	if _, _, err := client.GetOHLC(context.Background(), pair, 240, 0); err == nil {
+		t.Error("intervalle de 4h accepté, attendu une erreur")
+	}
+}
+
+func TestArchiveDataCoinbase(t *testing.T) {
+	client := newTestCoinbaseClient(newCoinbaseFixtureServer(t, nil))
+	client.Universe = PairSelection{Pairs: []string{"BTC/USD", "ETH-USD"}}
+	db := InitDB(filepath.Join(t.TempDir(), "crypto.db"))
+	defer db.Close()
+
+	ArchiveData(context.Background(), client, db)
+
+	rows, err := db.Query("SELECT exchange, pair, ask_price, bid_price, last_trade_price, high_24h FROM crypto_history ORDER BY pair")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var exchange, pair string
+		var ask, bid, last, high Decimal
+		if err := rows.Scan(&exchange, &pair, &ask, &bid, &last, &high); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strings.Join([]string{exchange, pair, string(ask), string(bid), string(last), string(high)}, " "))
+	}
+	want := []string{
+		"coinbase ETHUSD 1899.58 1899.57 1899.57 1911.99",
+		"coinbase XBTUSD 83912.02 83912.01 83912.01 84327.29",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("relevés archivés:\n%s\nattendu:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	var universe int
+	db.QueryRow("SELECT COUNT(*) FROM pair_universe WHERE exchange = 'coinbase'").Scan(&universe)
+	if universe != 2 {
+		t.Errorf("univers coinbase = %d paires, attendu 2", universe)
+	}
+}
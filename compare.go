@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ------------------- Comparaison des prix entre exchanges -------------------
+
+// Historique des écarts retourné par défaut : les dernières 24h par intervalles de 5 minutes.
+// Un prix archivé plus ancien que defaultQuoteMaxAge n'entre pas dans la comparaison :
+// un exchange dont l'archivage est arrêté fausserait l'écart.
+const (
+	defaultSpreadHistory  = 24 * time.Hour
+	defaultSpreadInterval = "5m"
+	defaultQuoteMaxAge    = 5 * time.Minute
+)
+
+// Meilleurs prix d'une paire sur un exchange
+type VenueQuote struct {
+	Exchange  string  `json:"exchange"`
+	Bid       Decimal `json:"bid"`
+	Ask       Decimal `json:"ask"`
+	Timestamp string  `json:"timestamp,omitempty"`
+}
+
+// Meilleurs prix d'une paire tous exchanges confondus et écart entre eux.
+// Spread = BestBid - BestAsk : positif, il mesure le gain d'un achat au meilleur ask
+// revendu immédiatement au meilleur bid d'un autre exchange (opportunité d'arbitrage).
+type CrossSpread struct {
+	BestBid         Decimal `json:"best_bid"`
+	BestBidExchange string  `json:"best_bid_exchange"`
+	BestAsk         Decimal `json:"best_ask"`
+	BestAskExchange string  `json:"best_ask_exchange"`
+	Spread          Decimal `json:"spread"`
+	SpreadBps       float64 `json:"spread_bps"` // En points de base du meilleur ask
+	Exchanges       int     `json:"exchanges"`  // Nombre d'exchanges cotant la paire
+}
+
+// Écart entre exchanges sur un intervalle de temps, calculé à partir du dernier
+// relevé de chaque exchange dans l'intervalle
+type SpreadPoint struct {
+	Time string `json:"time"` // Début de l'intervalle (RFC3339, UTC)
+	CrossSpread
+}
+
+// Comparaison des prix d'une paire entre exchanges
+type Comparison struct {
+	Pair    string        `json:"pair"`
+	Symbol  string        `json:"symbol,omitempty"`
+	Live    bool          `json:"live"` // Prix interrogés en direct plutôt que lus dans l'archive
+	Venues  []VenueQuote  `json:"venues"`
+	Best    *CrossSpread  `json:"best"` // null si moins de deux exchanges cotent la paire
+	History []SpreadPoint `json:"history"`
+}
+
+// crossSpread calcule les meilleurs prix et l'écart entre exchanges
+// (nil si moins de deux exchanges cotent la paire : il n'y a alors rien à comparer)
+func crossSpread(quotes []VenueQuote) *CrossSpread {
+	var spread *CrossSpread
+	for _, q := range quotes {
+		if q.Bid == "" || q.Ask == "" {
+			continue
+		}
+		if spread == nil {
+			spread = &CrossSpread{BestBid: q.Bid, BestBidExchange: q.Exchange, BestAsk: q.Ask, BestAskExchange: q.Exchange}
+		}
+		spread.Exchanges++
+		if q.Bid.Cmp(spread.BestBid) > 0 {
+			spread.BestBid, spread.BestBidExchange = q.Bid, q.Exchange
+		}
+		if q.Ask.Cmp(spread.BestAsk) < 0 {
+			spread.BestAsk, spread.BestAskExchange = q.Ask, q.Exchange
+		}
+	}
+	if spread == nil || spread.Exchanges < 2 {
+		return nil
+	}
+	spread.Spread = spread.BestBid.Sub(spread.BestAsk)
+	if ask := spread.BestAsk.Float64(); ask > 0 {
+		spread.SpreadBps = spread.Spread.Float64() / ask * 10000
+	}
+	return spread
+}
+
+// LatestQuotes lit le dernier bid/ask archivé de la paire sur chaque exchange.
+// Les exchanges dont le dernier relevé est antérieur à since sont ignorés (aucun filtre si since est nul).
+func LatestQuotes(ctx context.Context, db *sql.DB, pair string, since time.Time) ([]VenueQuote, error) {
+	query := `SELECT exchange, bid_price, ask_price, timestamp
+		FROM crypto_latest
+		WHERE pair = ? AND bid_price IS NOT NULL AND ask_price IS NOT NULL`
+	args := []interface{}{pair}
+	if !since.IsZero() {
+		query += " AND timestamp >= ?"
+		args = append(args, since.UTC().Format(time.RFC3339))
+	}
+	rows, err := db.QueryContext(ctx, query+" ORDER BY exchange", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quotes := []VenueQuote{}
+	for rows.Next() {
+		var q VenueQuote
+		if err := rows.Scan(&q.Exchange, &q.Bid, &q.Ask, &q.Timestamp); err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes, rows.Err()
+}
+
+// FetchQuotes interroge en parallèle le bid/ask de la paire sur chaque exchange qui la suit.
+// Un exchange en erreur ou ne suivant pas la paire est ignoré.
+func FetchQuotes(ctx context.Context, exchanges []Exchange, pair string) []VenueQuote {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	quotes := []VenueQuote{}
+	for _, ex := range exchanges {
+		wg.Add(1)
+		go func(ex Exchange) {
+			defer wg.Done()
+			pairs, err := ex.ListPairs(ctx)
+			if err != nil {
+				return
+			}
+			for _, p := range pairs {
+				if p.AltName != pair {
+					continue
+				}
+				tick, err := ex.GetTicker(ctx, p)
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				quotes = append(quotes, VenueQuote{
+					Exchange:  ex.Name(),
+					Bid:       tick.Bid,
+					Ask:       tick.Ask,
+					Timestamp: time.Now().UTC().Format(time.RFC3339),
+				})
+				mu.Unlock()
+				return
+			}
+		}(ex)
+	}
+	wg.Wait()
+
+	sort.Slice(quotes, func(i, j int) bool {
+		return quotes[i].Exchange < quotes[j].Exchange
+	})
+	return quotes
+}
+
+// SpreadHistory calcule l'écart entre exchanges de la paire par intervalle, à partir
+// des relevés archivés (from et to inclus, ignorés s'ils sont nuls). Les relevés sont
+// lus en flux ; seul le dernier de chaque exchange dans l'intervalle est retenu.
+func SpreadHistory(ctx context.Context, db *sql.DB, pair string, interval time.Duration, from, to time.Time) ([]SpreadPoint, error) {
+	query := `SELECT exchange, bid_price, ask_price, timestamp FROM crypto_history
+		WHERE pair = ? AND bid_price IS NOT NULL AND ask_price IS NOT NULL`
+	args := []interface{}{pair}
+	if !from.IsZero() {
+		query += " AND timestamp >= ?"
+		args = append(args, from.UTC().Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		query += " AND timestamp <= ?"
+		args = append(args, to.UTC().Format(time.RFC3339))
+	}
+	query += " ORDER BY timestamp, id"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []SpreadPoint{}
+	var currentStart time.Time
+	current := make(map[string]VenueQuote)
+
+	// flush ajoute le point de l'intervalle en cours, exchanges triés pour un résultat stable
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		quotes := make([]VenueQuote, 0, len(current))
+		for _, q := range current {
+			quotes = append(quotes, q)
+		}
+		sort.Slice(quotes, func(i, j int) bool {
+			return quotes[i].Exchange < quotes[j].Exchange
+		})
+		if spread := crossSpread(quotes); spread != nil {
+			points = append(points, SpreadPoint{Time: currentStart.Format(time.RFC3339), CrossSpread: *spread})
+		}
+		current = make(map[string]VenueQuote)
+	}
+
+	for rows.Next() {
+		var q VenueQuote
+		if err := rows.Scan(&q.Exchange, &q.Bid, &q.Ask, &q.Timestamp); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339, q.Timestamp)
+		if err != nil {
+			// Relevé au format inattendu : ignoré plutôt que de fausser l'écart
+			continue
+		}
+		start := t.UTC().Truncate(interval)
+		if !start.Equal(currentStart) {
+			flush()
+			currentStart = start
+		}
+		current[q.Exchange] = q
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return points, nil
+}
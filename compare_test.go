@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCrossSpread(t *testing.T) {
+	spread := crossSpread([]VenueQuote{
+		{Exchange: "kraken", Bid: "100.5", Ask: "100.7"},
+		{Exchange: "binance", Bid: "100.90", Ask: "101.00"},
+		{Exchange: "coinbase", Bid: "100.1", Ask: "100.4"},
+		{Exchange: "vide"}, // Sans prix : ignoré
+	})
+	if spread == nil {
+		t.Fatal("crossSpread = nil")
+	}
+	if spread.BestBid != "100.90" || spread.BestBidExchange != "binance" {
+		t.Errorf("meilleur bid = %s (%s), attendu 100.90 (binance)", spread.BestBid, spread.BestBidExchange)
+	}
+	if spread.BestAsk != "100.4" || spread.BestAskExchange != "coinbase" {
+		t.Errorf("meilleur ask = %s (%s), attendu 100.4 (coinbase)", spread.BestAsk, spread.BestAskExchange)
+	}
+	if spread.Spread != "0.50" {
+		t.Errorf("écart = %s, attendu 0.50", spread.Spread)
+	}
+	if want := 0.5 / 100.4 * 10000; math.Abs(spread.SpreadBps-want) > 1e-9 {
+		t.Errorf("écart = %f bps, attendu %f", spread.SpreadBps, want)
+	}
+	if spread.Exchanges != 3 {
+		t.Errorf("%d exchanges, attendu 3", spread.Exchanges)
+	}
+
+	if crossSpread(nil) != nil {
+		t.Error("crossSpread sans prix devrait retourner nil")
+	}
+	if spread := crossSpread([]VenueQuote{{Exchange: "kraken", Bid: "100.5", Ask: "100.7"}, {Exchange: "vide"}}); spread != nil {
+		t.Errorf("crossSpread avec un seul exchange = %+v, attendu nil", spread)
+	}
+}
+
+func TestCompareHandler(t *testing.T) {
+	// Le serveur HTTP crée son dossier d'exports CSV dans le répertoire courant
+	t.Chdir(t.TempDir())
+	db := InitDB(filepath.Join(t.TempDir(), "crypto.db"))
+	defer db.Close()
+	ctx := context.Background()
+
+	// Deux minutes de relevés : Kraken et Binance, puis Kraken seul
+	for _, tick := range []Tick{
+		{Exchange: "kraken", Pair: "XBTUSDT", Bid: "100.0", Ask: "100.2", Timestamp: "2025-01-01T00:00:10Z"},
+		{Exchange: "binance", Pair: "XBTUSDT", Bid: "100.3", Ask: "100.4", Timestamp: "2025-01-01T00:00:20Z"},
+		{Exchange: "kraken", Pair: "XBTUSDT", Bid: "100.1", Ask: "100.25", Timestamp: "2025-01-01T00:00:50Z"},
+		{Exchange: "kraken", Pair: "XBTUSDT", Bid: "99.9", Ask: "100.1", Timestamp: "2025-01-01T00:01:10Z"},
+	} {
+		InsertCryptoData(ctx, db, &tick)
+	}
+
+	binance := newTestBinanceClient(newBinanceFixtureServer(t, nil))
+	binance.Universe = PairSelection{Pairs: []string{"BTC/USDT"}}
+	handler := setupHTTPServer(NewKrakenClient("http://127.0.0.1:0", time.Second), db, []Exchange{binance}).Handler
+
+	get := func(url string) (int, Comparison) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		var comparison Comparison
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &comparison); err != nil {
+				t.Fatalf("%s: %v", url, err)
+			}
+		}
+		return rec.Code, comparison
+	}
+
+	// Relevés de 2025 : seuls comparés sans limite d'âge
+	code, comparison := get("/api/compare/BTC-USDT?interval=1m&from=2025-01-01&max_age=0")
+	if code != http.StatusOK {
+		t.Fatalf("statut %d", code)
+	}
+	if comparison.Pair != "XBTUSDT" || comparison.Live || len(comparison.Venues) != 2 {
+		t.Fatalf("comparaison = %+v", comparison)
+	}
+	if comparison.Venues[0].Exchange != "binance" || comparison.Venues[1].Bid != "99.9" {
+		t.Errorf("prix par exchange = %+v", comparison.Venues)
+	}
+	// Binance 100.3 contre Kraken 100.1 : le marché est croisé de 0.2
+	best := comparison.Best
+	if best.BestBid != "100.3" || best.BestBidExchange != "binance" || best.BestAsk != "100.1" || best.BestAskExchange != "kraken" || best.Spread != "0.2" {
+		t.Errorf("meilleurs prix = %+v", best)
+	}
+
+	// La seconde minute, cotée par Kraken seul, n'a pas d'écart
+	if len(comparison.History) != 1 {
+		t.Fatalf("historique = %+v, attendu 1 point", comparison.History)
+	}
+	// Première minute : dernier relevé Kraken (100.1/100.25) contre Binance (100.3/100.4)
+	if first := comparison.History[0]; first.Time != "2025-01-01T00:00:00Z" || first.Exchanges != 2 || first.Spread != "0.05" || first.BestAskExchange != "kraken" {
+		t.Errorf("premier point = %+v", first)
+	}
+
+	// Avec l'âge maximal par défaut, les relevés de 2025 sont périmés
+	if code, _ := get("/api/compare/XBTUSDT"); code != http.StatusNotFound {
+		t.Errorf("prix périmés: statut %d, attendu 404", code)
+	}
+	// Un relevé Kraken récent : Binance, périmé, est écarté et il n'y a plus d'écart à calculer
+	InsertCryptoData(ctx, db, &Tick{Exchange: "kraken", Pair: "XBTUSDT", Bid: "100.0", Ask: "100.2", Timestamp: time.Now().UTC().Format(time.RFC3339)})
+	code, comparison = get("/api/compare/XBTUSDT")
+	if code != http.StatusOK || len(comparison.Venues) != 1 || comparison.Venues[0].Exchange != "kraken" || comparison.Best != nil {
+		t.Errorf("prix récents: statut %d, %+v", code, comparison)
+	}
+	if code, _ := get("/api/compare/XBTUSDT?max_age=5"); code != http.StatusBadRequest {
+		t.Errorf("max_age invalide: statut %d, attendu 400", code)
+	}
+
+	code, comparison = get("/api/compare/XBTUSDT?live=true&from=2025-01-01")
+	if code != http.StatusOK || !comparison.Live || len(comparison.Venues) != 1 || comparison.Venues[0].Bid != "83912.01000000" {
+		t.Errorf("comparaison en direct: statut %d, %+v", code, comparison)
+	}
+
+	if code, _ := get("/api/compare/ETHUSD"); code != http.StatusNotFound {
+		t.Errorf("paire inconnue: statut %d, attendu 404", code)
+	}
+	if code, _ := get("/api/compare/XBTUSDT?interval=2m"); code != http.StatusBadRequest {
+		t.Errorf("intervalle invalide: statut %d, attendu 400", code)
+	}
+}
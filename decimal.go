@@ -0,0 +1,183 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ------------------- Nombres décimaux exacts -------------------
+
+// Nombre décimal exact (prix, volume), conservé sous la forme textuelle renvoyée
+// par l'exchange (ex: "0.00001234" ou "65000.10000") : aucun passage par float64
+// entre l'API Kraken, la base et les sorties JSON et CSV.
+// La valeur vide représente une donnée absente (NULL en base, null en JSON).
+type Decimal string
+
+// Formats acceptés : chiffres avec une partie décimale optionnelle, suivis ou non d'un
+// exposant décimal. NaN, les infinis et les flottants hexadécimaux sont refusés.
+var (
+	decimalPattern  = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+	exponentPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?[eE][+-]?[0-9]+$`)
+)
+
+// ParseDecimal valide un nombre décimal. Les notations avec exposant ("1e-05"),
+// produites par d'anciens relevés stockés en REAL, sont converties en notation fixe.
+func ParseDecimal(value string) (Decimal, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "+")
+	if decimalPattern.MatchString(value) {
+		return Decimal(value), nil
+	}
+	if !exponentPattern.MatchString(value) {
+		return "", fmt.Errorf("nombre décimal invalide: %q", value)
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		// Exposant hors des limites d'un float64 ("1e999")
+		return "", fmt.Errorf("nombre décimal invalide: %q", value)
+	}
+	return decimalFromFloat(f), nil
+}
+
+// decimalFromFloat convertit un flottant avec le moins de chiffres possible
+// (utilisé pour les anciennes valeurs REAL et les nombres JSON sans guillemets)
+func decimalFromFloat(f float64) Decimal {
+	return Decimal(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// String retourne la représentation exacte du nombre (vide s'il est absent)
+func (d Decimal) String() string {
+	return string(d)
+}
+
+// Float64 retourne une approximation du nombre pour les calculs (classements, écarts)
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(string(d), 64)
+	return f
+}
+
+// rat retourne la valeur exacte du nombre (zéro s'il est absent)
+func (d Decimal) rat() *big.Rat {
+	r, ok := new(big.Rat).SetString(string(d))
+	if !ok {
+		return new(big.Rat)
+	}
+	return r
+}
+
+// Cmp compare exactement deux nombres : -1 si d < other, 0 s'ils sont égaux, 1 sinon
+func (d Decimal) Cmp(other Decimal) int {
+	return d.rat().Cmp(other.rat())
+}
+
+// Add retourne la somme exacte d + other, avec autant de décimales que le plus précis des deux
+func (d Decimal) Add(other Decimal) Decimal {
+	scale := max(d.scale(), other.scale())
+	return Decimal(new(big.Rat).Add(d.rat(), other.rat()).FloatString(scale))
+}
+
+// Sub retourne la différence exacte d - other, avec autant de décimales que le plus précis des deux
+func (d Decimal) Sub(other Decimal) Decimal {
+	scale := max(d.scale(), other.scale())
+	return Decimal(new(big.Rat).Sub(d.rat(), other.rat()).FloatString(scale))
+}
+
+// scale retourne le nombre de chiffres après la virgule
+func (d Decimal) scale() int {
+	if _, frac, ok := strings.Cut(string(d), "."); ok {
+		return len(frac)
+	}
+	return 0
+}
+
+// IsZero indique si le nombre est nul (ou absent)
+func (d Decimal) IsZero() bool {
+	return d.rat().Sign() == 0
+}
+
+// Normalize supprime les zéros non significatifs de la partie décimale
+// ("65000.10000" devient "65000.1"), pour comparer des prix comme clés
+func (d Decimal) Normalize() Decimal {
+	s := string(d)
+	if !strings.Contains(s, ".") {
+		return d
+	}
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	return Decimal(s)
+}
+
+// MarshalJSON écrit le nombre tel quel, sans guillemets ni arrondi (null s'il est absent)
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	if d == "" {
+		return []byte("null"), nil
+	}
+	return []byte(d), nil
+}
+
+// UnmarshalJSON accepte un nombre sous forme de chaîne (format Kraken) ou de nombre JSON
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if value == "null" || value == "" {
+		*d = ""
+		return nil
+	}
+	parsed, err := ParseDecimal(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Scan lit un nombre depuis SQLite : texte, ou REAL/INTEGER pour les anciens relevés
+func (d *Decimal) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		*d = ""
+		return nil
+	case string:
+		return d.UnmarshalJSON([]byte(value))
+	case []byte:
+		return d.UnmarshalJSON(value)
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("nombre décimal invalide: %v", value)
+		}
+		*d = decimalFromFloat(value)
+		return nil
+	case int64:
+		*d = Decimal(strconv.FormatInt(value, 10))
+		return nil
+	}
+	return fmt.Errorf("type inattendu pour un nombre décimal: %T", src)
+}
+
+// Value enregistre le nombre sous forme de texte (NULL s'il est absent)
+func (d Decimal) Value() (driver.Value, error) {
+	if d == "" {
+		return nil, nil
+	}
+	return string(d), nil
+}
+
+// decimalFromJSON lit un nombre d'un tableau JSON décodé sans type ([]interface{}) :
+// chaîne (format Kraken) ou nombre
+func decimalFromJSON(v interface{}) Decimal {
+	switch value := v.(type) {
+	case string:
+		d, _ := ParseDecimal(value)
+		return d
+	case float64:
+		return decimalFromFloat(value)
+	case json.Number:
+		d, _ := ParseDecimal(value.String())
+		return d
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := map[string]Decimal{
+		"65000.10000": "65000.10000",
+		"0.000012340": "0.000012340",
+		" +42 ":       "42",
+		"-1.5":        "-1.5",
+		"1e-05":       "0.00001", // Anciens relevés stockés en REAL
+		"1.2345E+04":  "12345",
+	}
+	for value, want := range tests {
+		if got, err := ParseDecimal(value); err != nil || got != want {
+			t.Errorf("ParseDecimal(%q) = %q, %v, attendu %q", value, got, err, want)
+		}
+	}
+	// Valeurs non finies et flottants hexadécimaux : acceptés par strconv.ParseFloat,
+	// ils produiraient un JSON invalide
+	for _, value := range []string{"", "abc", "1,5", "NaN?", "NaN", "nan", "Inf", "+Inf", "-Inf", "Infinity", "-infinity", "0x1p-2", "0X1.8P3", "1e999", "1e", ".5e3", "1_000"} {
+		if _, err := ParseDecimal(value); err == nil {
+			t.Errorf("ParseDecimal(%q) accepté", value)
+		}
+	}
+
+	var d Decimal
+	if err := json.Unmarshal([]byte(`"NaN"`), &d); err == nil {
+		t.Errorf("NaN accepté en JSON: %q", d)
+	}
+	if err := d.Scan(math.Inf(1)); err == nil {
+		t.Errorf("REAL infini accepté: %q", d)
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	if got := Decimal("65000.10").Sub("64999.955"); got != "0.145" {
+		t.Errorf("Sub = %q, attendu 0.145", got)
+	}
+	if got := Decimal("0.1").Add("0.2"); got != "0.3" {
+		t.Errorf("Add = %q, attendu 0.3 (sans erreur d'arrondi flottant)", got)
+	}
+	if got := Decimal("").Add("1.50"); got != "1.50" {
+		t.Errorf("Add sur une valeur absente = %q, attendu 1.50", got)
+	}
+	if Decimal("65000.10000").Cmp("65000.1") != 0 || Decimal("0.00000001").Cmp("0") <= 0 || Decimal("-2").Cmp("1") >= 0 {
+		t.Error("Cmp ne compare pas exactement")
+	}
+	if !Decimal("0.0000").IsZero() || !Decimal("").IsZero() || Decimal("0.00000001").IsZero() {
+		t.Error("IsZero incorrect")
+	}
+	for value, want := range map[Decimal]Decimal{"65000.10000": "65000.1", "42.000": "42", "100": "100", "": ""} {
+		if got := value.Normalize(); got != want {
+			t.Errorf("Normalize(%q) = %q, attendu %q", value, got, want)
+		}
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	// Chaînes (format Kraken), nombres JSON et valeurs absentes
+	var decoded struct {
+		Price  Decimal `json:"price"`
+		Volume Decimal `json:"volume"`
+		Low    Decimal `json:"low"`
+	}
+	if err := json.Unmarshal([]byte(`{"price":"0.000012340","volume":12.50,"low":null}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Price != "0.000012340" || decoded.Volume != "12.50" || decoded.Low != "" {
+		t.Errorf("décodage = %+v", decoded)
+	}
+	if err := json.Unmarshal([]byte(`{"price":"beaucoup"}`), &decoded); err == nil {
+		t.Error("nombre invalide accepté")
+	}
+
+	// Écrit tel quel, sans guillemets ni arrondi
+	data, err := json.Marshal(decoded)
+	if err != nil || string(data) != `{"price":0.000012340,"volume":12.50,"low":null}` {
+		t.Errorf("encodage = %s, %v", data, err)
+	}
+}
+
+func TestDecimalSQL(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "decimal.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE numbers (id INTEGER PRIMARY KEY, value)"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Texte exact, NULL, et anciennes valeurs REAL et INTEGER
+	for _, value := range []interface{}{Decimal("65000.10000"), Decimal(""), 1e-05, 42} {
+		if _, err := db.Exec("INSERT INTO numbers (value) VALUES (?)", value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows, err := db.Query("SELECT value FROM numbers ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []Decimal
+	for rows.Next() {
+		var d Decimal
+		if err := rows.Scan(&d); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, d)
+	}
+	want := []Decimal{"65000.10000", "", "0.00001", "42"}
+	if len(got) != len(want) {
+		t.Fatalf("valeurs lues = %q, attendu %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("valeurs lues = %q, attendu %q", got, want)
+			break
+		}
+	}
+
+	var stored string
+	if err := db.QueryRow("SELECT typeof(value) FROM numbers WHERE id = 1").Scan(&stored); err != nil || stored != "text" {
+		t.Errorf("Decimal stocké en %s, attendu text", stored)
+	}
+}
+
+func TestDecimalFromJSON(t *testing.T) {
+	var raw []interface{}
+	json.Unmarshal([]byte(`["65000.1", 0.5, true]`), &raw)
+	if decimalFromJSON(raw[0]) != "65000.1" || decimalFromJSON(raw[1]) != "0.5" || decimalFromJSON(raw[2]) != "" {
+		t.Errorf("decimalFromJSON = %q, %q, %q", decimalFromJSON(raw[0]), decimalFromJSON(raw[1]), decimalFromJSON(raw[2]))
+	}
+	if decimalFromJSON(json.Number("12.30")) != "12.30" {
+		t.Error("json.Number mal converti")
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ------------------- Instantanés du carnet d'ordres -------------------
+
+// Nombre de niveaux par défaut de chaque côté du carnet
+const defaultBookDepth = 10
+
+// Structure représentant un niveau du carnet d'ordres
+type BookLevel struct {
+	Price     Decimal `json:"price"`
+	Volume    Decimal `json:"volume"`
+	Timestamp int64   `json:"timestamp"` // Dernière mise à jour du niveau (Unix)
+}
+
+// UnmarshalJSON décode un niveau Kraken : [price, volume, timestamp]
+func (l *BookLevel) UnmarshalJSON(data []byte) error {
+	var raw []interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) < 3 {
+		return fmt.Errorf("niveau du carnet incomplet: %s", data)
+	}
+
+	l.Price = decimalFromJSON(raw[0])
+	l.Volume = decimalFromJSON(raw[1])
+	l.Timestamp = int64(parseKrakenTime(raw[2]))
+	return nil
+}
+
+// Structure représentant un instantané du carnet d'ordres d'une paire
+type BookSnapshot struct {
+	Pair   string      `json:"pair"`
+	Symbol string      `json:"symbol,omitempty"` // Forme canonique BASE/QUOTE (BTC/USD)
+	Time   string      `json:"time"`
+	Depth  int         `json:"depth"`
+	Asks   []BookLevel `json:"asks"`
+	Bids   []BookLevel `json:"bids"`
+}
+
+// GetDepth récupère le carnet d'ordres d'une paire sur count niveaux
+func (c *KrakenClient) GetDepth(ctx context.Context, pair string, count int) (*BookSnapshot, error) {
+	params := url.Values{}
+	params.Set("pair", pair)
+	params.Set("count", strconv.Itoa(count))
+
+	var response struct {
+		Error  []string `json:"error"`
+		Result map[string]struct {
+			Asks []BookLevel `json:"asks"`
+			Bids []BookLevel `json:"bids"`
+		} `json:"result"`
+	}
+	if err := c.get(ctx, "/0/public/Depth", params, &response); err != nil {
+		return nil, err
+	}
+	for _, book := range response.Result {
+		return &BookSnapshot{
+			Pair:  pair,
+			Time:  time.Now().UTC().Format(time.RFC3339),
+			Depth: count,
+			Asks:  book.Asks,
+			Bids:  book.Bids,
+		}, nil
+	}
+	return nil, fmt.Errorf("No depth data found for %s", pair)
+}
+
+// encodeBookLevels sérialise des niveaux pour la base : tableau JSON de [prix, volume, timestamp].
+// Les prix et volumes y sont écrits comme nombres JSON avec tous leurs chiffres.
+func encodeBookLevels(levels []BookLevel) (string, error) {
+	rows := make([][3]interface{}, len(levels))
+	for i, l := range levels {
+		rows[i] = [3]interface{}{l.Price, l.Volume, l.Timestamp}
+	}
+	data, err := json.Marshal(rows)
+	return string(data), err
+}
+
+// decodeBookLevels relit des niveaux sérialisés par encodeBookLevels
+func decodeBookLevels(data string) ([]BookLevel, error) {
+	var rows [][3]json.Number
+	if err := json.Unmarshal([]byte(data), &rows); err != nil {
+		return nil, err
+	}
+	levels := make([]BookLevel, len(rows))
+	for i, row := range rows {
+		timestamp, _ := row[2].Float64()
+		levels[i] = BookLevel{Price: decimalFromJSON(row[0]), Volume: decimalFromJSON(row[1]), Timestamp: int64(timestamp)}
+	}
+	return levels, nil
+}
+
+// InsertBookSnapshot enregistre un instantané du carnet d'ordres
+func InsertBookSnapshot(ctx context.Context, db *sql.DB, snapshot *BookSnapshot) error {
+	asks, err := encodeBookLevels(snapshot.Asks)
+	if err != nil {
+		return err
+	}
+	bids, err := encodeBookLevels(snapshot.Bids)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx,
+		"INSERT INTO book_snapshots (pair, time, depth, asks, bids) VALUES (?, ?, ?, ?, ?)",
+		snapshot.Pair, snapshot.Time, snapshot.Depth, asks, bids,
+	)
+	return err
+}
+
+// scanBookSnapshot lit un instantané depuis une ligne de book_snapshots
+func scanBookSnapshot(row *sql.Row) (*BookSnapshot, error) {
+	var snapshot BookSnapshot
+	var asks, bids string
+	if err := row.Scan(&snapshot.Pair, &snapshot.Time, &snapshot.Depth, &asks, &bids); err != nil {
+		return nil, err
+	}
+	var err error
+	if snapshot.Asks, err = decodeBookLevels(asks); err != nil {
+		return nil, err
+	}
+	if snapshot.Bids, err = decodeBookLevels(bids); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// GetNearestBookSnapshot retourne l'instantané d'une paire le plus proche de la date at
+// (nil s'il n'y en a aucun)
+func GetNearestBookSnapshot(ctx context.Context, db *sql.DB, pair string, at time.Time) (*BookSnapshot, error) {
+	atStr := at.UTC().Format(time.RFC3339)
+	columns := "SELECT pair, time, depth, asks, bids FROM book_snapshots"
+
+	// Instantanés immédiatement avant et après la date demandée (via l'index pair, time)
+	before, err := scanBookSnapshot(db.QueryRowContext(ctx,
+		columns+" WHERE pair = ? AND time <= ? ORDER BY time DESC, id DESC LIMIT 1", pair, atStr))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	after, err := scanBookSnapshot(db.QueryRowContext(ctx,
+		columns+" WHERE pair = ? AND time > ? ORDER BY time, id LIMIT 1", pair, atStr))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if before == nil || after == nil {
+		if before != nil {
+			return before, nil
+		}
+		return after, nil
+	}
+
+	beforeTime, _ := time.Parse(time.RFC3339, before.Time)
+	afterTime, _ := time.Parse(time.RFC3339, after.Time)
+	if at.Sub(beforeTime) <= afterTime.Sub(at) {
+		return before, nil
+	}
+	return after, nil
+}
+
+// CollectDepthContinuously enregistre un instantané du carnet de chaque paire à intervalles réguliers.
+// Sans liste explicite, les paires sont celles de l'archivage, rafraîchies toutes les heures.
+func CollectDepthContinuously(ctx context.Context, client *KrakenClient, db *sql.DB, pairNames []string, levels int, interval time.Duration, wg *sync.WaitGroup) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer wg.Done()
+
+	pairs := &collectorPairs{client: client, names: pairNames}
+
+	for {
+		select {
+		case <-ticker.C:
+			cycleCtx, cancel := context.WithTimeout(ctx, interval)
+			for _, pair := range pairs.Get(cycleCtx) {
+				if cycleCtx.Err() != nil {
+					break
+				}
+				snapshot, err := client.GetDepth(cycleCtx, pair.InternalName, levels)
+				if err != nil {
+					log.Println("Erreur récupération du carnet pour", pair.AltName, ":", err)
+					continue
+				}
+				// Stocker avec le nom alternatif, comme les relevés du ticker
+				snapshot.Pair = pair.AltName
+				if err := InsertBookSnapshot(cycleCtx, db, snapshot); err != nil {
+					log.Println("Erreur lors de l'insertion du carnet pour", pair.AltName, ":", err)
+				}
+			}
+			cancel()
+
+		case <-ctx.Done():
+			log.Println("Collecte du carnet d'ordres arrêtée")
+			return
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ------------------- Exchanges -------------------
+
+// Noms des exchanges pris en charge, tels qu'enregistrés dans la colonne exchange
+const (
+	krakenExchangeName   = "kraken"
+	binanceExchangeName  = "binance"
+	coinbaseExchangeName = "coinbase"
+	defaultExchange      = krakenExchangeName
+)
+
+// Interface commune aux exchanges archivés. Les paires sont désignées par le nom
+// stocké en base (AltName, au format Kraken : XBTUSD, ETHUSDT...) et par leur
+// identifiant natif sur l'exchange (InternalName).
+type Exchange interface {
+	// Name retourne le nom de l'exchange (kraken, binance, coinbase)
+	Name() string
+	// ServerTime retourne l'heure du serveur de l'exchange
+	ServerTime(ctx context.Context) (time.Time, error)
+	// ListPairs retourne les paires à archiver, selon la politique de sélection de l'exchange
+	ListPairs(ctx context.Context) ([]PairMapping, error)
+	// GetTickers retourne le dernier relevé de chaque paire, indexé par nom stocké.
+	// Si une partie des requêtes échoue, les relevés obtenus sont retournés avec l'erreur.
+	GetTickers(ctx context.Context, pairs []PairMapping) (map[string]*Tick, error)
+	// GetTicker interroge les meilleurs prix d'une seule paire par la requête la plus
+	// légère de l'exchange (comparaison des prix en direct). Seuls Pair, Exchange,
+	// Ask et Bid sont garantis.
+	GetTicker(ctx context.Context, pair PairMapping) (*Tick, error)
+	// GetOHLC retourne les bougies d'une paire depuis le curseur since (timestamp Unix)
+	// et le curseur de la requête suivante
+	GetOHLC(ctx context.Context, pair PairMapping, intervalMinutes int, since int64) ([]OHLCEntry, int64, error)
+}
+
+// Noms Kraken des actifs dont le symbole usuel diffère (BTC -> XBT)
+var krakenAssetNames = map[string]string{
+	"BTC":  "XBT",
+	"DOGE": "XDG",
+}
+
+// canonicalPairName construit le nom stocké d'une paire à partir des symboles usuels de
+// ses actifs, selon la convention des noms alternatifs Kraken (BTC/USD -> XBTUSD), pour
+// que la même paire porte le même nom quel que soit l'exchange.
+func canonicalPairName(base, quote string) string {
+	base, quote = normalizeSymbol(base), normalizeSymbol(quote)
+	if name, ok := krakenAssetNames[base]; ok {
+		base = name
+	}
+	if name, ok := krakenAssetNames[quote]; ok {
+		quote = name
+	}
+	return base + quote
+}
+
+// Adaptateur de l'API Kraken vers l'interface Exchange
+type KrakenExchange struct {
+	Client *KrakenClient
+}
+
+// NewKrakenExchange crée l'adaptateur Exchange d'un client Kraken
+func NewKrakenExchange(client *KrakenClient) *KrakenExchange {
+	return &KrakenExchange{Client: client}
+}
+
+func (k *KrakenExchange) Name() string {
+	return krakenExchangeName
+}
+
+func (k *KrakenExchange) ServerTime(ctx context.Context) (time.Time, error) {
+	serverTime, err := k.Client.GetServerStatus(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(serverTime.Unixtime, 0).UTC(), nil
+}
+
+// ListPairs retourne les paires sélectionnées, réévaluées toutes les RankingTTL
+func (k *KrakenExchange) ListPairs(ctx context.Context) ([]PairMapping, error) {
+	return k.Client.GetAssetPairs(ctx)
+}
+
+func (k *KrakenExchange) GetTickers(ctx context.Context, pairs []PairMapping) (map[string]*Tick, error) {
+	ticks := make(map[string]*Tick)
+	if len(pairs) == 0 {
+		// Sans liste de paires, GetTickers interrogerait tout le catalogue
+		return ticks, nil
+	}
+	// Utiliser les noms internes pour la requête Ticker, en une seule passe par lots
+	var names []string
+	for _, pair := range pairs {
+		names = append(names, pair.InternalName)
+	}
+	tickers, err := k.Client.GetTickers(ctx, names)
+	for _, pair := range pairs {
+		if info, ok := tickers[pair.InternalName]; ok {
+			ticks[pair.AltName] = info.Tick(pair.AltName, "")
+		}
+	}
+	return ticks, err
+}
+
+func (k *KrakenExchange) GetTicker(ctx context.Context, pair PairMapping) (*Tick, error) {
+	info, err := k.Client.GetTicker(ctx, pair.InternalName)
+	if err != nil {
+		return nil, err
+	}
+	return info.Tick(pair.AltName, ""), nil
+}
+
+func (k *KrakenExchange) GetOHLC(ctx context.Context, pair PairMapping, intervalMinutes int, since int64) ([]OHLCEntry, int64, error) {
+	return k.Client.GetOHLC(ctx, pair.InternalName, intervalMinutes, since)
+}
+
+// addExchangeFlags déclare le choix des exchanges archivés et retourne une fonction
+// construisant leurs adaptateurs une fois les options lues. Les autres exchanges
+// reprennent le délai, le User-Agent et la politique de sélection du client Kraken.
+func addExchangeFlags(fs *flag.FlagSet) func(client *KrakenClient) ([]Exchange, error) {
+	names := fs.String("exchanges", defaultExchange, "Exchanges archivés, séparés par des virgules (kraken, binance, coinbase)")
+	binanceURL := fs.String("binance-url", defaultBinanceBaseURL, "URL de base de l'API Binance (serveur local, proxy...)")
+	coinbaseURL := fs.String("coinbase-url", defaultCoinbaseBaseURL, "URL de base de l'API Coinbase Exchange (serveur local, proxy...)")
+	return func(client *KrakenClient) ([]Exchange, error) {
+		var exchanges []Exchange
+		for _, name := range splitList(*names) {
+			switch strings.ToLower(name) {
+			case krakenExchangeName:
+				exchanges = append(exchanges, NewKrakenExchange(client))
+			case binanceExchangeName:
+				binance := NewBinanceClient(*binanceURL, client.HTTPClient.Timeout)
+				binance.UserAgent = client.UserAgent
+				binance.RankingTTL = client.RankingTTL
+				binance.Universe = client.Universe
+				exchanges = append(exchanges, binance)
+			case coinbaseExchangeName:
+				coinbase := NewCoinbaseClient(*coinbaseURL, client.HTTPClient.Timeout)
+				coinbase.UserAgent = client.UserAgent
+				coinbase.RankingTTL = client.RankingTTL
+				coinbase.Universe = client.Universe
+				exchanges = append(exchanges, coinbase)
+			default:
+				return nil, fmt.Errorf("exchange inconnu: %s (attendu: kraken, binance ou coinbase)", name)
+			}
+		}
+		if len(exchanges) == 0 {
+			return nil, fmt.Errorf("aucun exchange sélectionné")
+		}
+		return exchanges, nil
+	}
+}
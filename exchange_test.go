@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LouisVannobel/crypto-archive/krakenfake"
+)
+
+// Dossier des réponses enregistrées, résolu avant que des tests ne changent de répertoire
+var testdataDir, _ = filepath.Abs("testdata")
+
+// newFixtureServer simule un exchange à partir des réponses enregistrées dans
+// testdata/<dir> : fixture associe le chemin d'une requête au fichier servi (sans
+// extension). Une requête sans fichier reçoit le contenu de error.json avec errorStatus.
+// Les requêtes reçues sont ajoutées à requests.
+func newFixtureServer(t *testing.T, dir string, errorStatus int, fixture func(path string) string, requests *[]*http.Request) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil {
+			*requests = append(*requests, r)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if name := fixture(r.URL.Path); name != "" {
+			if data, err := os.ReadFile(filepath.Join(testdataDir, dir, name+".json")); err == nil {
+				w.Write(data)
+				return
+			}
+		}
+		data, _ := os.ReadFile(filepath.Join(testdataDir, dir, "error.json"))
+		w.WriteHeader(errorStatus)
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCanonicalPairName(t *testing.T) {
+	tests := map[[2]string]string{
+		{"BTC", "USD"}:   "XBTUSD",
+		{"btc", "usdt"}:  "XBTUSDT",
+		{"ETH", "BTC"}:   "ETHXBT",
+		{"DOGE", "USDT"}: "XDGUSDT",
+		{"XBT", "EUR"}:   "XBTEUR",
+		{"SOL", "USD"}:   "SOLUSD",
+	}
+	for assets, want := range tests {
+		if got := canonicalPairName(assets[0], assets[1]); got != want {
+			t.Errorf("canonicalPairName(%s, %s) = %s, attendu %s", assets[0], assets[1], got, want)
+		}
+	}
+}
+
+// Les adaptateurs Kraken (faux Kraken) et Binance (réponses enregistrées) respectent
+// le même contrat : relevés indexés et nommés par nom stocké, exchange renseigné
+func TestExchangeAdapters(t *testing.T) {
+	serverTime := time.UnixMilli(1741944413123).UTC()
+	fake := krakenfake.New()
+	fake.SetTime(serverTime)
+	kraken := newFakeKrakenClient(t, fake)
+	kraken.Universe = PairSelection{Pairs: []string{"BTC/USD"}}
+	binance := newTestBinanceClient(newBinanceFixtureServer(t, nil))
+	binance.Universe = PairSelection{Pairs: []string{"BTC/USDT"}}
+
+	tests := []struct {
+		exchange Exchange
+		name     string
+		pair     string
+	}{
+		{NewKrakenExchange(kraken), "kraken", "XBTUSD"},
+		{binance, "binance", "XBTUSDT"},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		ex := tt.exchange
+		if ex.Name() != tt.name {
+			t.Errorf("Name = %s, attendu %s", ex.Name(), tt.name)
+		}
+		if got, err := ex.ServerTime(ctx); err != nil || got.Unix() != serverTime.Unix() {
+			t.Errorf("%s: ServerTime = %v, %v", tt.name, got, err)
+		}
+
+		pairs, err := ex.ListPairs(ctx)
+		if err != nil || len(pairs) != 1 || pairs[0].AltName != tt.pair || pairs[0].Base != "BTC" {
+			t.Errorf("%s: ListPairs = %+v, %v", tt.name, pairs, err)
+			continue
+		}
+
+		ticks, err := ex.GetTickers(ctx, pairs)
+		tick := ticks[tt.pair]
+		if err != nil || len(ticks) != 1 || tick == nil || tick.Exchange != tt.name || tick.Pair != tt.pair || tick.Ask == "" || tick.Last == "" || tick.Volume == "" {
+			t.Errorf("%s: GetTickers = %v, %v", tt.name, ticks, err)
+		}
+
+		if tick, err := ex.GetTicker(ctx, pairs[0]); err != nil || tick.Exchange != tt.name || tick.Pair != tt.pair || tick.Ask.Cmp(tick.Bid) <= 0 {
+			t.Errorf("%s: GetTicker = %+v, %v", tt.name, tick, err)
+		}
+
+		entries, last, err := ex.GetOHLC(ctx, pairs[0], 60, 0)
+		if err != nil || len(entries) == 0 || last <= 0 || entries[0].Close == "" {
+			t.Errorf("%s: GetOHLC = %d bougie(s), curseur %d, %v", tt.name, len(entries), last, err)
+		}
+	}
+}
+
+func TestAddExchangeFlags(t *testing.T) {
+	build := func(args ...string) ([]Exchange, error) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		exchanges := addExchangeFlags(fs)
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		client := NewKrakenClient(defaultKrakenBaseURL, 3*time.Second)
+		client.Universe = PairSelection{Top: 5}
+		return exchanges(client)
+	}
+
+	exchanges, err := build()
+	if err != nil || len(exchanges) != 1 || exchanges[0].Name() != "kraken" {
+		t.Errorf("exchanges par défaut = %v, %v", exchanges, err)
+	}
+
+	// Les autres exchanges reprennent le délai et la sélection du client Kraken
+	exchanges, err = build("-exchanges", "kraken, Binance,coinbase", "-binance-url", "http://127.0.0.1:8082")
+	if err != nil || len(exchanges) != 3 {
+		t.Fatalf("exchanges = %v, %v", exchanges, err)
+	}
+	binance := exchanges[1].(*BinanceClient)
+	if binance.BaseURL != "http://127.0.0.1:8082" || binance.HTTPClient.Timeout != 3*time.Second || binance.Universe.Top != 5 {
+		t.Errorf("client Binance = %+v", binance)
+	}
+	if exchanges[2].Name() != "coinbase" {
+		t.Errorf("troisième exchange = %s, attendu coinbase", exchanges[2].Name())
+	}
+
+	for _, value := range []string{"kraken,ftx", " , "} {
+		if _, err := build("-exchanges", value); err == nil {
+			t.Errorf("-exchanges %q accepté", value)
+		}
+	}
+}
@@ -2,4 +2,7 @@ module github.com/LouisVannobel/crypto-archive
 
 go 1.24
 
-require github.com/mattn/go-sqlite3 v1.14.25 // indirect
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/mattn/go-sqlite3 v1.14.25
+)
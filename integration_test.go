@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LouisVannobel/crypto-archive/krakenfake"
+)
+
+// newFakeKrakenClient démarre un faux Kraken et retourne un client configuré pour les
+// tests : limiteur permissif (pour les métriques) et réessais quasi immédiats
+func newFakeKrakenClient(t *testing.T, fake *krakenfake.Server) *KrakenClient {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := NewKrakenClient(server.URL, 5*time.Second)
+	client.Limiter = NewRateLimiter(RateLimit{Rate: 1000, Burst: 1000}, nil)
+	client.RetryBaseDelay = time.Millisecond
+	client.RetryMaxDelay = 5 * time.Millisecond
+	client.Universe = PairSelection{Pairs: []string{"XBTUSD", "ETH/USD"}}
+	return client
+}
+
+func TestArchiveDataFakeKraken(t *testing.T) {
+	fake := krakenfake.New()
+	fake.ScriptPrices("XBTUSD", "50000.0", "50100.5")
+	client := newFakeKrakenClient(t, fake)
+	db := InitDB(filepath.Join(t.TempDir(), "crypto.db"))
+	defer db.Close()
+	ctx := context.Background()
+	ex := NewKrakenExchange(client)
+
+	// Premier cycle : dépassement de débit puis erreur 502, réessayés
+	fake.FailNext("Ticker", krakenfake.RateLimited, krakenfake.BadGateway)
+	ArchiveData(ctx, ex, db)
+	ArchiveData(ctx, ex, db)
+
+	// Erreur définitive : pas de réessai ; le lot puis chaque paire seule sont refusés, aucun relevé
+	fake.FailNext("Ticker", krakenfake.UnknownPair, krakenfake.UnknownPair, krakenfake.UnknownPair)
+	ArchiveData(ctx, ex, db)
+
+	rows, err := db.Query("SELECT exchange, pair, ask_price, bid_price, last_trade_price, open_price FROM crypto_history ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var exchange, pair string
+		var ask, bid, last, open Decimal
+		if err := rows.Scan(&exchange, &pair, &ask, &bid, &last, &open); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strings.Join([]string{exchange, pair, string(ask), string(bid), string(last), string(open)}, " "))
+	}
+	want := []string{
+		"kraken ETHUSD 3000.01 3000.00 3000.00 2975.00",
+		"kraken XBTUSD 50000.0 50000.0 50000.0 49500.0",
+		"kraken ETHUSD 3000.01 3000.00 3000.00 2975.00",
+		"kraken XBTUSD 50100.5 50100.5 50100.5 49500.0",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("relevés archivés:\n%s\nattendu:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// 2 erreurs réessayées + 2 cycles réussis + 3 erreurs définitives ; la sélection est en cache
+	if calls := fake.Calls("Ticker"); calls != 7 {
+		t.Errorf("%d appels Ticker, attendu 7", calls)
+	}
+	if calls := fake.Calls("AssetPairs"); calls != 1 {
+		t.Errorf("%d appels AssetPairs, attendu 1", calls)
+	}
+	if stats := client.Limiter.Stats(); stats[0].RateLimitErrors != 1 {
+		t.Errorf("dépassements de débit = %d, attendu 1", stats[0].RateLimitErrors)
+	}
+}
+
+func TestArchiveDataUnknownPairFakeKraken(t *testing.T) {
+	fake := krakenfake.New()
+	client := newFakeKrakenClient(t, fake)
+	db := InitDB(filepath.Join(t.TempDir(), "crypto.db"))
+	defer db.Close()
+	ctx := context.Background()
+	ex := NewKrakenExchange(client)
+
+	ArchiveData(ctx, ex, db)
+
+	// Paire retirée par Kraken alors que la sélection en cache la suit encore :
+	// tout le lot est refusé, seule la paire retirée doit manquer
+	fake.RemovePair("XETHZUSD")
+	ArchiveData(ctx, ex, db)
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM crypto_history WHERE pair = 'XBTUSD'").Scan(&count); err != nil || count != 2 {
+		t.Errorf("%d relevé(s) XBTUSD, attendu 2 (%v)", count, err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM crypto_history WHERE pair = 'ETHUSD'").Scan(&count); err != nil || count != 1 {
+		t.Errorf("%d relevé(s) ETHUSD, attendu 1 (%v)", count, err)
+	}
+
+	// Lot refusé puis une requête par paire, sans réessai de l'erreur définitive
+	tickers, err := client.GetTickers(ctx, []string{"XXBTZUSD", "XDGUSD", "XXBTZEUR"})
+	if len(tickers) != 2 || tickers["XXBTZUSD"].Ask == nil || tickers["XXBTZEUR"].Ask == nil {
+		t.Errorf("tickers = %v", tickers)
+	}
+	if !IsKrakenErrorKind(err, ErrPermanent) {
+		t.Errorf("erreur = %v, attendu la paire inconnue", err)
+	}
+	if calls := fake.Calls("Ticker"); calls != 1+3+4 {
+		t.Errorf("%d appels Ticker, attendu 8", calls)
+	}
+}
+
+func TestRankingCacheFakeKraken(t *testing.T) {
+	fake := krakenfake.New()
+	client := newFakeKrakenClient(t, fake)
+	client.Universe = PairSelection{Top: 2}
+	client.MaxRetries = 0
+	ctx := context.Background()
+
+	// Tickers indisponibles au premier classement : aucune paire, l'erreur est remontée
+	fake.FailNext("Ticker", krakenfake.Unavailable)
+	pairs, err := client.GetAssetPairs(ctx)
+	if !IsKrakenErrorKind(err, ErrUnavailable) || len(pairs) != 0 {
+		t.Fatalf("sélection sans classement = %v, %v", pairs, err)
+	}
+
+	// Le classement est refait au cycle suivant, puis gardé en cache
+	for range 2 {
+		pairs, err = client.GetAssetPairs(ctx)
+		if err != nil || len(pairs) != 2 || pairs[0].AltName != "XBTUSD" || pairs[1].AltName != "XBTEUR" {
+			t.Fatalf("sélection = %v, %v", pairs, err)
+		}
+	}
+	if calls := fake.Calls("Ticker"); calls != 2 {
+		t.Errorf("%d appels Ticker, attendu 2", calls)
+	}
+
+	// Classement expiré mais incomplet : la sélection précédente est conservée
+	client.RankingTTL = 0
+	fake.SetPrice("XETHZUSD", "1000000.0")
+	fake.FailNext("Ticker", krakenfake.Unavailable)
+	if pairs, err := client.GetAssetPairs(ctx); err != nil || len(pairs) != 2 || pairs[1].AltName != "XBTEUR" {
+		t.Errorf("sélection après un classement incomplet = %v, %v", pairs, err)
+	}
+	if pairs, err := client.GetAssetPairs(ctx); err != nil || len(pairs) != 2 || pairs[0].AltName != "ETHUSD" {
+		t.Errorf("sélection après un classement complet = %v, %v", pairs, err)
+	}
+
+	// De même pour le classement exposé par l'API
+	ranking, err := client.GetRanking(ctx, "USD")
+	if err != nil || ranking.Pairs[0].Pair != "ETHUSD" {
+		t.Fatalf("classement = %+v, %v", ranking, err)
+	}
+	fake.SetPrice("XETHZUSD", "1.0")
+	fake.FailNext("Ticker", krakenfake.Unavailable)
+	if ranking, err := client.GetRanking(ctx, "USD"); err != nil || ranking.Pairs[0].Pair != "ETHUSD" {
+		t.Errorf("classement après des tickers incomplets = %+v, %v", ranking, err)
+	}
+}
+
+func TestRankingSingleTickerRequestFakeKraken(t *testing.T) {
+	// Catalogue de 30 paires : le classement ne doit pas interroger les tickers par lots
+	fake := krakenfake.New()
+	for i := range 30 {
+		fake.AddPair(krakenfake.Pair{
+			Name: fmt.Sprintf("ALT%dUSD", i), AltName: fmt.Sprintf("ALT%dUSD", i), WSName: fmt.Sprintf("ALT%d/USD", i),
+			Base: fmt.Sprintf("ALT%d", i), Quote: "ZUSD",
+			Ticker: krakenfake.Ticker{Ask: "1.01", Bid: "1.00", Last: "1.00", Volume: fmt.Sprint(1000 * i), Open: "1.00"},
+		})
+	}
+	client := newFakeKrakenClient(t, fake)
+	client.Universe = PairSelection{Top: 3, Exclude: []string{"BTC/*", "ETH/*", "SOL/*"}}
+
+	pairs, err := client.GetAssetPairs(context.Background())
+	if err != nil || len(pairs) != 3 || pairs[0].AltName != "ALT29USD" || pairs[2].AltName != "ALT27USD" {
+		t.Fatalf("sélection = %v, %v", pairs, err)
+	}
+	if calls := fake.Calls("Ticker"); calls != 1 {
+		t.Errorf("%d appels Ticker pour le classement, attendu 1", calls)
+	}
+}
+
+// seedFakeKraken remplit une base à partir du faux Kraken : catalogues, deux cycles
+// d'archivage, bougies horaires rapatriées, trades et un instantané du carnet
+func seedFakeKraken(t *testing.T, fake *krakenfake.Server, client *KrakenClient, dbPath string) {
+	t.Helper()
+	db := InitDB(dbPath)
+	defer db.Close()
+	ctx := context.Background()
+	ex := NewKrakenExchange(client)
+
+	if _, err := RefreshAssets(ctx, client, db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RefreshPairs(ctx, client, db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deux cycles à une minute d'intervalle
+	fake.ScriptPrices("XBTUSD", "50000.0", "50100.5")
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	ArchiveDataAt(ctx, ex, db, start)
+	ArchiveDataAt(ctx, ex, db, start.Add(time.Minute))
+
+	pairs, err := ex.ListPairs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Backfill(ctx, ex, db, pairs, 60, 0)
+
+	fake.AddTrades("XBTUSD",
+		krakenfake.Trade{ID: 1, Price: "50000.0", Volume: "0.5", Time: 1735689600.25, Side: "b", OrderType: "m"},
+		krakenfake.Trade{ID: 2, Price: "50001.5", Volume: "0.1", Time: 1735689601.5, Side: "s", OrderType: "l"},
+	)
+	for _, pair := range pairs {
+		if pair.AltName != "XBTUSD" {
+			continue
+		}
+		if count, err := CollectTrades(ctx, client, db, pair); err != nil || count != 2 {
+			t.Fatalf("CollectTrades = %d, %v ; attendu 2 trades", count, err)
+		}
+		snapshot, err := client.GetDepth(ctx, pair.InternalName, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapshot.Pair = pair.AltName
+		if err := InsertBookSnapshot(ctx, db, snapshot); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readCSV relit un export du dossier CSV
+func readCSV(t *testing.T, filename string) [][]string {
+	t.Helper()
+	file, err := os.Open(filepath.Join(initCSVDirectory(), filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestExportsFakeKraken(t *testing.T) {
+	// Les exports sont écrits dans data/csv du répertoire courant
+	t.Chdir(t.TempDir())
+	fake := krakenfake.New()
+	client := newFakeKrakenClient(t, fake)
+	dbPath := filepath.Join(t.TempDir(), "crypto.db")
+	seedFakeKraken(t, fake, client, dbPath)
+	db := InitDB(dbPath)
+	defer db.Close()
+	ctx := context.Background()
+
+	filename, err := ExportAllPairsToSingleCSV(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := readCSV(t, filename)
+	// En-tête puis le dernier relevé de chaque paire
+	if len(records) != 3 || len(records[0]) != len(tickColumns) {
+		t.Fatalf("export global = %v", records)
+	}
+
+	filename, err = ExportPairToCSV(ctx, db, krakenExchangeName, "XBTUSD", []tickColumn{tickColumns[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records = readCSV(t, filename)
+	if len(records) != 3 || records[2][0] != "XBTUSD" || !strings.HasPrefix(filename, "kraken_XBTUSD_") {
+		t.Errorf("export de XBTUSD (%s) = %v", filename, records)
+	}
+
+	filename, err = ExportCandlesToCSV(ctx, db, krakenExchangeName, "XBTUSD", "1m", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records = readCSV(t, filename)
+	if len(records) != 3 || records[1][1] != "2025-01-01T12:00:00Z" || records[1][5] != "50000.0" || records[2][5] != "50100.5" {
+		t.Errorf("export des bougies = %v", records)
+	}
+}
+
+func TestHTTPHandlersFakeKraken(t *testing.T) {
+	t.Chdir(t.TempDir())
+	fake := krakenfake.New()
+	fake.SetTime(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	client := newFakeKrakenClient(t, fake)
+	dbPath := filepath.Join(t.TempDir(), "crypto.db")
+	seedFakeKraken(t, fake, client, dbPath)
+	db := InitDB(dbPath)
+	defer db.Close()
+
+	handler := setupHTTPServer(client, db, []Exchange{NewKrakenExchange(client)}).Handler
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		return rec
+	}
+
+	tests := []struct {
+		url      string
+		status   int
+		contains []string
+	}{
+		{"/", http.StatusOK, []string{"Crypto Archive API", "/api/compare/"}},
+		// Sans export global existant, le dernier relevé de chaque paire est exporté à la demande
+		{"/api/export-latest", http.StatusOK, []string{"Pair,", "ETHUSD,", "XBTUSD,"}},
+		{"/api/status", http.StatusOK, []string{`"server_time":1735732800`, `"database_ok":true`}},
+		{"/api/metrics", http.StatusOK, []string{`"bucket":"global"`}},
+		{"/api/pairs", http.StatusOK, []string{`["ETHUSD","XBTUSD"]`}},
+		{"/api/pairs?exchange=binance", http.StatusOK, []string{"null"}},
+		// Le volume de XBTEUR converti en dollars dépasse celui d'ETHUSD
+		{"/api/rankings?currency=USD&limit=3", http.StatusOK, []string{`"rank":1,"pair":"XBTUSD"`, `"rank":2,"pair":"XBTEUR"`, `"rank":3,"pair":"ETHUSD"`}},
+		{"/api/rankings?currency=USD&quotes=EUR", http.StatusOK, []string{`"pair":"XBTEUR"`}},
+		{"/api/pairs/BTC-USD", http.StatusOK, []string{`"altname":"XBTUSD"`, `"symbol":"BTC/USD"`, `"tick_size":0.1`}},
+		{"/api/pairs/DOGEUSD", http.StatusNotFound, nil},
+		{"/api/data/", http.StatusOK, []string{`"pair":"ETHUSD"`, `"pair":"XBTUSD"`}},
+		{"/api/data/XBTUSD", http.StatusOK, []string{`"ask":50100.5`, `"symbol":"BTC/USD"`}},
+		{"/api/data/BTC-USD?limit=1&order=asc&columns=pair,ask", http.StatusOK, []string{`"data":[{"ask":50000.0,"pair":"XBTUSD","symbol":"BTC/USD"}]`, `"next_cursor"`}},
+		{"/api/data/XBTUSD?columns=nope", http.StatusBadRequest, nil},
+		{"/api/data/DOGEUSD", http.StatusNotFound, nil},
+		{"/api/candles/XBTUSD?interval=1m", http.StatusOK, []string{`"time":"2025-01-01T12:00:00Z"`, `"close":50100.5`}},
+		{"/api/candles/XBTUSD?interval=1h&source=kraken&from=2025-01-01T03:00:00Z", http.StatusOK, []string{`"time":"2025-01-01T03:00:00Z"`, `"time":"2025-01-01T12:00:00Z"`}},
+		{"/api/candles/XBTUSD?source=nope", http.StatusBadRequest, nil},
+		{"/api/book/BTC-USD", http.StatusOK, []string{`"depth":10`, `"asks":[{"price":50100.5,`, `"bids":[{"price":50100.5,`}},
+		{"/api/book/ETHUSD", http.StatusNotFound, nil},
+		{"/api/compare/XBTUSD?live=true", http.StatusOK, []string{`"live":true`, `"exchange":"kraken"`}},
+		{"/api/export/XBTUSD", http.StatusOK, []string{"Pair,", "XBTUSD,"}},
+		{"/api/export/XBTUSD?interval=1h", http.StatusOK, []string{"Pair,Time,Open"}},
+		{"/api/export/XBTUSD?interval=2m", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		rec := get(tt.url)
+		if rec.Code != tt.status {
+			t.Errorf("%s: statut %d, attendu %d (%s)", tt.url, rec.Code, tt.status, strings.TrimSpace(rec.Body.String()))
+			continue
+		}
+		for _, s := range tt.contains {
+			if !strings.Contains(rec.Body.String(), s) {
+				t.Errorf("%s: %q absent de la réponse:\n%s", tt.url, s, rec.Body.String())
+			}
+		}
+	}
+
+	// Kraken indisponible : le statut remonte une erreur une fois les réessais épuisés
+	client.MaxRetries = 1
+	fake.FailNext("Time", krakenfake.Unavailable, krakenfake.Busy)
+	if rec := get("/api/status"); rec.Code != http.StatusInternalServerError {
+		t.Errorf("statut avec Kraken indisponible: %d, attendu 500", rec.Code)
+	}
+	if calls := fake.Calls("Time"); calls != 3 {
+		t.Errorf("%d appels Time, attendu 3", calls)
+	}
+
+	var metrics struct {
+		RateLimiter []RateLimiterStats `json:"rate_limiter"`
+	}
+	if err := json.Unmarshal(get("/api/metrics").Body.Bytes(), &metrics); err != nil || len(metrics.RateLimiter) == 0 || metrics.RateLimiter[0].Requests == 0 {
+		t.Errorf("métriques = %+v, %v", metrics, err)
+	}
+}
+
+func TestExportHandlerFailure(t *testing.T) {
+	t.Chdir(t.TempDir())
+	fake := krakenfake.New()
+	fake.SetTime(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	client := newFakeKrakenClient(t, fake)
+	dbPath := filepath.Join(t.TempDir(), "crypto.db")
+	seedFakeKraken(t, fake, client, dbPath)
+	db := InitDB(dbPath)
+	defer db.Close()
+
+	// Un fichier à la place du répertoire CSV fait échouer la création de l'export
+	if err := os.MkdirAll("data", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("data/csv", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := setupHTTPServer(client, db, []Exchange{NewKrakenExchange(client)}).Handler
+	for _, url := range []string{"/api/export/XBTUSD?interval=1h", "/api/export/XBTUSD", "/api/export/XBTUSD?columns=pair,ask"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("%s: statut %d, attendu 500 (%s)", url, rec.Code, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+}
+
+func TestMarketPairsSortedFakeKraken(t *testing.T) {
+	client := newFakeKrakenClient(t, krakenfake.New())
+	// Le catalogue est lu dans une map : l'ordre doit être fixé pour que les
+	// requêtes soient identiques d'un cycle à l'autre (et rejouables)
+	for i := 0; i < 5; i++ {
+		pairs, err := client.marketPairs(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, pair := range pairs {
+			names = append(names, pair.InternalName)
+		}
+		if got, want := strings.Join(names, ","), "SOLUSD,XETHZUSD,XXBTZEUR,XXBTZUSD"; got != want {
+			t.Fatalf("paires = %s, attendu %s", got, want)
+		}
+	}
+}
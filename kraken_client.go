@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------- Client de l'API Kraken -------------------
+
+// Valeurs par défaut du client Kraken
+const (
+	defaultKrakenBaseURL   = "https://api.kraken.com"
+	defaultKrakenTimeout   = 15 * time.Second
+	defaultKrakenUserAgent = "crypto-archive"
+	defaultRankingTTL      = 1 * time.Hour
+	defaultMaxRetries      = 4
+	defaultRetryBaseDelay  = 500 * time.Millisecond
+	defaultRetryMaxDelay   = 30 * time.Second
+)
+
+// Structure portant la configuration des appels à l'API publique de Kraken.
+// L'URL de base peut pointer vers un serveur local (tests) ou un proxy.
+type KrakenClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	UserAgent  string
+	RankingTTL time.Duration // Intervalle de réévaluation de la sélection des paires suivies
+	Universe   PairSelection // Politique de sélection des paires suivies par l'archivage
+	Limiter    *RateLimiter  // Limiteur de débit partagé par tous les appels (nil : aucun)
+
+	// Réessais des erreurs temporaires, avec un délai exponentiel aléatoire
+	// (borné par l'échéance du contexte de l'appel)
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+
+	selection selectionCache // Paires suivies, réévaluées toutes les RankingTTL
+
+	// Derniers tickers de toutes les paires, pour le classement par volume
+	marketMu sync.Mutex
+	market   []PairRanking
+	marketAt time.Time
+}
+
+// NewKrakenClient crée un client vers baseURL avec un délai maximal par requête
+func NewKrakenClient(baseURL string, timeout time.Duration) *KrakenClient {
+	return &KrakenClient{
+		BaseURL:    baseURL,
+		HTTPClient: &http.Client{Timeout: timeout},
+		UserAgent:  defaultKrakenUserAgent,
+		RankingTTL: defaultRankingTTL,
+		Universe:   PairSelection{Top: defaultUniverseTop},
+		Limiter:    NewRateLimiter(RateLimit{Rate: defaultRateLimit, Burst: defaultRateBurst}, nil),
+
+		MaxRetries:     defaultMaxRetries,
+		RetryBaseDelay: defaultRetryBaseDelay,
+		RetryMaxDelay:  defaultRetryMaxDelay,
+	}
+}
+
+// addKrakenFlags déclare les options de configuration du client Kraken
+// et retourne une fonction construisant le client une fois les options lues
+func addKrakenFlags(fs *flag.FlagSet) func() (*KrakenClient, error) {
+	baseURL := fs.String("kraken-url", defaultKrakenBaseURL, "URL de base de l'API Kraken (serveur local, proxy...)")
+	timeout := fs.Duration("kraken-timeout", defaultKrakenTimeout, "Délai maximal d'une requête à l'API Kraken")
+	userAgent := fs.String("user-agent", defaultKrakenUserAgent, "User-Agent envoyé à l'API Kraken")
+	rankingTTL := fs.Duration("ranking-ttl", defaultRankingTTL, "Intervalle de réévaluation de la sélection des paires suivies")
+	rateLimit := fs.String("rate-limit", fmt.Sprintf("%g:%g", defaultRateLimit, defaultRateBurst),
+		"Limite globale des appels Kraken, en requêtes par seconde:réserve")
+	endpointRateLimits := fs.String("endpoint-rate-limits", "",
+		"Limites supplémentaires par endpoint (ex: OHLC=0.5:2,Trades=1:5)")
+	maxRetries := fs.Int("kraken-retries", defaultMaxRetries, "Nombre maximal de réessais d'un appel Kraken en erreur temporaire")
+	recordDir := fs.String("record-dir", "", "Dossier où enregistrer les réponses brutes de l'API Kraken (rejouables avec replay)")
+	return func() (*KrakenClient, error) {
+		global, err := ParseRateLimit(*rateLimit)
+		if err != nil {
+			return nil, fmt.Errorf("option -rate-limit invalide: %v", err)
+		}
+		endpoints, err := ParseEndpointRateLimits(*endpointRateLimits)
+		if err != nil {
+			return nil, fmt.Errorf("option -endpoint-rate-limits invalide: %v", err)
+		}
+
+		client := NewKrakenClient(*baseURL, *timeout)
+		client.UserAgent = *userAgent
+		client.RankingTTL = *rankingTTL
+		client.Limiter = NewRateLimiter(global, endpoints)
+		client.MaxRetries = *maxRetries
+		if *recordDir != "" {
+			recorder, err := NewRecordingTransport(*recordDir, client.HTTPClient.Transport)
+			if err != nil {
+				return nil, fmt.Errorf("option -record-dir invalide: %v", err)
+			}
+			client.HTTPClient.Transport = recorder
+			log.Printf("Enregistrement des réponses Kraken dans %s", *recordDir)
+		}
+		return client, nil
+	}
+}
+
+// get appelle un endpoint public (ex: "/0/public/Ticker") et décode la réponse JSON dans out.
+// Les erreurs temporaires (réseau, HTTP 5xx, service indisponible, débit dépassé) sont
+// réessayées avec un délai exponentiel aléatoire, sans dépasser l'échéance de ctx.
+// Les erreurs renvoyées sont des *KrakenError.
+func (c *KrakenClient) get(ctx context.Context, path string, params url.Values, out interface{}) error {
+	endpoint := path[strings.LastIndex(path, "/")+1:]
+
+	for attempt := 0; ; attempt++ {
+		err := c.getOnce(ctx, endpoint, path, params, out)
+		if err == nil || !IsRetryable(err) || attempt >= c.MaxRetries {
+			return err
+		}
+
+		delay := c.retryDelay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			// Pas le temps de réessayer avant l'échéance du cycle
+			return err
+		}
+		log.Printf("Nouvel essai %d/%d de %s dans %s: %v", attempt+1, c.MaxRetries, endpoint, delay.Round(time.Millisecond), err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// retryDelay retourne un délai aléatoire entre 0 et base * 2^attempt, borné par RetryMaxDelay
+func (c *KrakenClient) retryDelay(attempt int) time.Duration {
+	ceiling := c.RetryBaseDelay << attempt
+	if ceiling <= 0 || ceiling > c.RetryMaxDelay {
+		ceiling = c.RetryMaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// getOnce effectue une tentative d'appel et classe l'erreur éventuelle
+func (c *KrakenClient) getOnce(ctx context.Context, endpoint, path string, params url.Values, out interface{}) error {
+	if c.Limiter != nil {
+		if err := c.Limiter.Wait(ctx, endpoint); err != nil {
+			return err
+		}
+	}
+
+	requestURL := c.BaseURL + path
+	if len(params) > 0 {
+		requestURL += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return &KrakenError{Kind: ErrPermanent, Endpoint: endpoint, Err: err}
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &KrakenError{Kind: ErrNetwork, Endpoint: endpoint, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		kind := classifyHTTPStatus(resp.StatusCode)
+		if kind == ErrRateLimit && c.Limiter != nil {
+			c.Limiter.RateLimited(endpoint)
+		}
+		return &KrakenError{Kind: kind, Endpoint: endpoint, StatusCode: resp.StatusCode}
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &KrakenError{Kind: ErrNetwork, Endpoint: endpoint, StatusCode: resp.StatusCode, Err: err}
+	}
+
+	// Lire le champ "error" commun à toutes les réponses avant de décoder le résultat
+	var envelope struct {
+		Error []string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return &KrakenError{Kind: ErrServer, Endpoint: endpoint, StatusCode: resp.StatusCode, Err: err}
+	}
+	if len(envelope.Error) > 0 {
+		kind := classifyKrakenMessages(envelope.Error)
+		if kind == ErrRateLimit && c.Limiter != nil {
+			c.Limiter.RateLimited(endpoint)
+		}
+		return &KrakenError{Kind: kind, Endpoint: endpoint, StatusCode: resp.StatusCode, Messages: envelope.Error}
+	}
+	if c.Limiter != nil {
+		c.Limiter.Succeeded(endpoint)
+	}
+
+	// Réponse valide mais de structure inattendue : la réessayer redonnerait la même erreur
+	if err := json.Unmarshal(body, out); err != nil {
+		return &KrakenError{Kind: ErrPermanent, Endpoint: endpoint, StatusCode: resp.StatusCode, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestKrakenClientBaseURL(t *testing.T) {
+	var path, userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, userAgent = r.URL.Path, r.UserAgent()
+		fmt.Fprint(w, `{"error":[],"result":{"unixtime":1735732800,"rfc1123":"Wed,  1 Jan 25 12:00:00 +0000"}}`)
+	}))
+	defer server.Close()
+
+	// L'URL de base peut comporter un chemin (proxy), préfixé à celui de l'endpoint
+	client := NewKrakenClient(server.URL+"/kraken", time.Second)
+	client.UserAgent = "archive-test/1.0"
+	status, err := client.GetServerStatus(context.Background())
+	if err != nil || status.Unixtime != 1735732800 {
+		t.Fatalf("GetServerStatus = %+v, %v", status, err)
+	}
+	if path != "/kraken/0/public/Time" || userAgent != "archive-test/1.0" {
+		t.Errorf("requête reçue : %s (User-Agent %q)", path, userAgent)
+	}
+}
+
+func TestKrakenClientTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewKrakenClient(server.URL, 50*time.Millisecond)
+	client.MaxRetries = 0
+	start := time.Now()
+	_, err := client.GetServerStatus(context.Background())
+	if !IsKrakenErrorKind(err, ErrNetwork) {
+		t.Errorf("erreur = %v, attendu une erreur réseau", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("délai dépassé après %v, attendu 50ms", elapsed)
+	}
+}
+
+func TestAddKrakenFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	build := addKrakenFlags(fs)
+	if err := fs.Parse([]string{"-kraken-url", "http://127.0.0.1:8081", "-kraken-timeout", "3s", "-user-agent", "proxy-test", "-kraken-retries", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	client, err := build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.BaseURL != "http://127.0.0.1:8081" || client.HTTPClient.Timeout != 3*time.Second || client.UserAgent != "proxy-test" || client.MaxRetries != 1 {
+		t.Errorf("client = %+v", client)
+	}
+
+	// Sans option, le client appelle l'API publique de Kraken
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	build = addKrakenFlags(fs)
+	fs.Parse(nil)
+	if client, _ := build(); client.BaseURL != defaultKrakenBaseURL || client.HTTPClient.Timeout != defaultKrakenTimeout {
+		t.Errorf("client par défaut = %+v", client)
+	}
+}
+
+func TestKrakenClientDecodeErrors(t *testing.T) {
+	calls := 0
+	body := `{"error":[],"result":{"unixtime":"pas un nombre"}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	client := NewKrakenClient(server.URL, time.Second)
+	client.Limiter = nil
+	client.RetryBaseDelay, client.RetryMaxDelay = time.Millisecond, time.Millisecond
+
+	// Résultat de structure inattendue : erreur définitive, sans réessai
+	if _, err := client.GetServerStatus(context.Background()); !IsKrakenErrorKind(err, ErrPermanent) || calls != 1 {
+		t.Errorf("résultat invalide : %v après %d appel(s), attendu une erreur définitive après 1 appel", err, calls)
+	}
+
+	// Réponse illisible (tronquée, page d'erreur d'un proxy) : erreur serveur, réessayée
+	calls, body = 0, `<html>Bad Gateway</html>`
+	if _, err := client.GetServerStatus(context.Background()); !IsKrakenErrorKind(err, ErrServer) || calls != 1+client.MaxRetries {
+		t.Errorf("réponse illisible : %v après %d appel(s), attendu une erreur serveur après %d appels", err, calls, 1+client.MaxRetries)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ------------------- Classification des erreurs Kraken -------------------
+
+// Catégorie d'erreur, qui détermine si l'appel peut être réessayé
+type KrakenErrorKind int
+
+const (
+	ErrNetwork     KrakenErrorKind = iota // Connexion impossible, délai dépassé, réponse tronquée
+	ErrServer                             // HTTP 5xx ou réponse illisible
+	ErrUnavailable                        // EService:Unavailable, EService:Busy...
+	ErrRateLimit                          // EAPI:Rate limit exceeded, HTTP 429
+	ErrPermanent                          // EQuery:Unknown asset pair, arguments invalides, résultat inattendu...
+)
+
+func (k KrakenErrorKind) String() string {
+	switch k {
+	case ErrNetwork:
+		return "network"
+	case ErrServer:
+		return "server"
+	case ErrUnavailable:
+		return "unavailable"
+	case ErrRateLimit:
+		return "rate_limit"
+	default:
+		return "permanent"
+	}
+}
+
+// Erreur d'un appel à l'API Kraken
+type KrakenError struct {
+	Kind       KrakenErrorKind
+	Endpoint   string   // Nom de l'endpoint (Ticker, OHLC...)
+	StatusCode int      // Code HTTP, 0 si la requête n'a pas abouti
+	Messages   []string // Erreurs renvoyées par Kraken dans le champ "error"
+	Err        error    // Erreur sous-jacente (réseau, décodage)
+}
+
+func (e *KrakenError) Error() string {
+	switch {
+	case len(e.Messages) > 0:
+		return fmt.Sprintf("API %s error: %v", e.Endpoint, e.Messages)
+	case e.Err != nil:
+		return fmt.Sprintf("API %s: %v", e.Endpoint, e.Err)
+	default:
+		return fmt.Sprintf("API %s: HTTP %d", e.Endpoint, e.StatusCode)
+	}
+}
+
+func (e *KrakenError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable indique si l'appel peut réussir en étant simplement réessayé
+func (e *KrakenError) Retryable() bool {
+	return e.Kind != ErrPermanent
+}
+
+// IsRetryable indique si une erreur est temporaire. L'annulation du contexte
+// (arrêt, échéance du cycle) n'est jamais réessayée.
+func IsRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var krakenErr *KrakenError
+	if errors.As(err, &krakenErr) {
+		return krakenErr.Retryable()
+	}
+	return false
+}
+
+// IsKrakenErrorKind indique si err est une erreur Kraken de la catégorie donnée
+func IsKrakenErrorKind(err error, kind KrakenErrorKind) bool {
+	var krakenErr *KrakenError
+	return errors.As(err, &krakenErr) && krakenErr.Kind == kind
+}
+
+// Préfixes des erreurs temporaires de Kraken
+var (
+	krakenRateLimitErrors = []string{
+		"EAPI:Rate limit exceeded",
+		"EGeneral:Too many requests",
+	}
+	krakenUnavailableErrors = []string{
+		"EService:Unavailable",
+		"EService:Busy",
+		"EGeneral:Temporary lockout",
+	}
+)
+
+// classifyKrakenMessages détermine la catégorie d'erreurs renvoyées dans le champ "error"
+func classifyKrakenMessages(messages []string) KrakenErrorKind {
+	hasPrefix := func(message string, prefixes []string) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(message, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, message := range messages {
+		if hasPrefix(message, krakenRateLimitErrors) {
+			return ErrRateLimit
+		}
+	}
+	for _, message := range messages {
+		if hasPrefix(message, krakenUnavailableErrors) {
+			return ErrUnavailable
+		}
+	}
+	return ErrPermanent
+}
+
+// classifyHTTPStatus détermine la catégorie d'une réponse HTTP en erreur
+func classifyHTTPStatus(statusCode int) KrakenErrorKind {
+	switch {
+	case statusCode == 429:
+		return ErrRateLimit
+	case statusCode >= 500:
+		return ErrServer
+	default:
+		return ErrPermanent
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestClassifyKrakenMessages(t *testing.T) {
+	tests := []struct {
+		messages []string
+		want     KrakenErrorKind
+	}{
+		{[]string{"EAPI:Rate limit exceeded"}, ErrRateLimit},
+		{[]string{"EGeneral:Too many requests"}, ErrRateLimit},
+		{[]string{"EService:Unavailable"}, ErrUnavailable},
+		{[]string{"EService:Busy"}, ErrUnavailable},
+		{[]string{"EGeneral:Temporary lockout"}, ErrUnavailable},
+		{[]string{"EQuery:Unknown asset pair"}, ErrPermanent},
+		{[]string{"EGeneral:Invalid arguments:pair"}, ErrPermanent},
+		// Le dépassement de débit l'emporte, puis l'indisponibilité, quel que soit l'ordre
+		{[]string{"EService:Busy", "EAPI:Rate limit exceeded"}, ErrRateLimit},
+		{[]string{"EQuery:Unknown asset pair", "EService:Unavailable"}, ErrUnavailable},
+	}
+	for _, tt := range tests {
+		if got := classifyKrakenMessages(tt.messages); got != tt.want {
+			t.Errorf("classifyKrakenMessages(%v) = %s, attendu %s", tt.messages, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyHTTPStatus(t *testing.T) {
+	tests := map[int]KrakenErrorKind{
+		http.StatusTooManyRequests:     ErrRateLimit,
+		http.StatusInternalServerError: ErrServer,
+		http.StatusBadGateway:          ErrServer,
+		http.StatusServiceUnavailable:  ErrServer,
+		http.StatusBadRequest:          ErrPermanent,
+		http.StatusNotFound:            ErrPermanent,
+	}
+	for status, want := range tests {
+		if got := classifyHTTPStatus(status); got != want {
+			t.Errorf("classifyHTTPStatus(%d) = %s, attendu %s", status, got, want)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&KrakenError{Kind: ErrNetwork}, true},
+		{&KrakenError{Kind: ErrServer}, true},
+		{&KrakenError{Kind: ErrUnavailable}, true},
+		{&KrakenError{Kind: ErrRateLimit}, true},
+		{&KrakenError{Kind: ErrPermanent}, false},
+		// Erreur Kraken enveloppée
+		{fmt.Errorf("cycle: %w", &KrakenError{Kind: ErrUnavailable}), true},
+		// L'annulation n'est jamais réessayée, même portée par une erreur réseau
+		{context.Canceled, false},
+		{context.DeadlineExceeded, false},
+		{&KrakenError{Kind: ErrNetwork, Err: context.DeadlineExceeded}, false},
+		{errors.New("autre erreur"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, attendu %v", tt.err, got, tt.want)
+		}
+	}
+
+	err := fmt.Errorf("cycle: %w", &KrakenError{Kind: ErrRateLimit, Endpoint: "Ticker"})
+	if !IsKrakenErrorKind(err, ErrRateLimit) || IsKrakenErrorKind(err, ErrServer) || IsKrakenErrorKind(errors.New("x"), ErrPermanent) {
+		t.Error("IsKrakenErrorKind ne reconnaît pas la catégorie")
+	}
+}
+
+func TestKrakenErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  *KrakenError
+		want string
+	}{
+		{&KrakenError{Endpoint: "Ticker", Messages: []string{"EQuery:Unknown asset pair"}}, "API Ticker error: [EQuery:Unknown asset pair]"},
+		{&KrakenError{Endpoint: "Time", Err: errors.New("connexion refusée")}, "API Time: connexion refusée"},
+		{&KrakenError{Endpoint: "OHLC", StatusCode: 502}, "API OHLC: HTTP 502"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, attendu %q", got, tt.want)
+		}
+	}
+}
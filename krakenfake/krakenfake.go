@@ -0,0 +1,639 @@
+// Package krakenfake simule l'API publique REST de Kraken (Time, Assets, AssetPairs,
+// Ticker, OHLC, Trades, Depth) pour le développement hors ligne et les tests.
+//
+// Server implémente http.Handler et se branche directement sur httptest :
+//
+//	fake := krakenfake.New()
+//	server := httptest.NewServer(fake)
+//	defer server.Close()
+//	client := NewKrakenClient(server.URL, 5*time.Second)
+//
+// Les prix se scriptent paire par paire (SetPrice, ScriptPrices, ScriptTickers) et
+// les erreurs s'injectent endpoint par endpoint (FailNext), y compris les réponses
+// de dépassement de débit de Kraken.
+package krakenfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Nombre maximal de bougies et de trades renvoyés par requête, comme chez Kraken
+const (
+	MaxCandles = 720
+	MaxTrades  = 1000
+)
+
+// Nombre de bougies et de niveaux du carnet générés pour une paire qui n'en a pas reçu
+const (
+	syntheticCandles = 10
+	syntheticLevels  = 25
+)
+
+// Ticker d'une paire. Les prix et volumes sont des décimaux sous forme de texte,
+// renvoyés tels quels.
+type Ticker struct {
+	Ask    string
+	Bid    string
+	Last   string
+	Volume string // Volume sur 24h
+	VWAP   string // Prix moyen pondéré sur 24h
+	Trades int    // Nombre de trades sur 24h
+	Low    string
+	High   string
+	Open   string
+}
+
+// Bougie OHLC (Time : début de l'intervalle, en secondes Unix)
+type Candle struct {
+	Time   int64
+	Open   string
+	High   string
+	Low    string
+	Close  string
+	VWAP   string
+	Volume string
+	Count  int
+}
+
+// Trade individuel (Time en secondes Unix, avec fraction ; Side "b" ou "s" ;
+// OrderType "m" ou "l")
+type Trade struct {
+	ID        int64
+	Price     string
+	Volume    string
+	Time      float64
+	Side      string
+	OrderType string
+}
+
+// Niveau du carnet d'ordres
+type Level struct {
+	Price  string
+	Volume string
+	Time   int64
+}
+
+// Paire de trading simulée, désignée par son nom interne (XXBTZUSD)
+type Pair struct {
+	Name    string // XXBTZUSD
+	AltName string // XBTUSD
+	WSName  string // XBT/USD
+	Base    string // XXBT
+	Quote   string // ZUSD
+	Ticker  Ticker
+}
+
+// Erreur renvoyée à la place d'une réponse : statut HTTP différent de 200 et/ou
+// messages dans le champ "error" de la réponse
+type Fault struct {
+	Status   int
+	Messages []string
+}
+
+// Erreurs courantes de l'API Kraken
+var (
+	RateLimited  = Fault{Messages: []string{"EAPI:Rate limit exceeded"}}
+	TooMany      = Fault{Status: http.StatusTooManyRequests}
+	Unavailable  = Fault{Messages: []string{"EService:Unavailable"}}
+	Busy         = Fault{Messages: []string{"EService:Busy"}}
+	BadGateway   = Fault{Status: http.StatusBadGateway}
+	UnknownPair  = Fault{Messages: []string{"EQuery:Unknown asset pair"}}
+	InvalidQuery = Fault{Messages: []string{"EGeneral:Invalid arguments"}}
+)
+
+// État simulé d'une paire
+type pairState struct {
+	Pair
+	script  []Ticker         // Tickers des prochains appels Ticker, dans l'ordre
+	candles map[int][]Candle // Bougies par intervalle (minutes)
+	trades  []Trade
+	asks    []Level
+	bids    []Level
+}
+
+// Faux serveur de l'API publique Kraken. Sans ordre contraire, chaque paire renvoie
+// le même ticker à chaque appel, des bougies plates au dernier prix, aucun trade et
+// un carnet régulier autour du bid et de l'ask.
+type Server struct {
+	mu     sync.Mutex
+	pairs  map[string]*pairState
+	order  []string // Noms internes, dans l'ordre d'ajout
+	now    func() time.Time
+	faults map[string][]Fault
+	calls  map[string]int
+}
+
+// DefaultPairs retourne les paires du serveur créé par New
+func DefaultPairs() []Pair {
+	return []Pair{
+		{Name: "XXBTZUSD", AltName: "XBTUSD", WSName: "XBT/USD", Base: "XXBT", Quote: "ZUSD", Ticker: Ticker{
+			Ask: "50000.1", Bid: "50000.0", Last: "50000.0", Volume: "1500.25", VWAP: "49800.5",
+			Trades: 25000, Low: "49000.0", High: "51000.0", Open: "49500.0",
+		}},
+		{Name: "XETHZUSD", AltName: "ETHUSD", WSName: "ETH/USD", Base: "XETH", Quote: "ZUSD", Ticker: Ticker{
+			Ask: "3000.01", Bid: "3000.00", Last: "3000.00", Volume: "12000.5", VWAP: "2990.25",
+			Trades: 18000, Low: "2950.00", High: "3050.00", Open: "2975.00",
+		}},
+		{Name: "XXBTZEUR", AltName: "XBTEUR", WSName: "XBT/EUR", Base: "XXBT", Quote: "ZEUR", Ticker: Ticker{
+			Ask: "46000.1", Bid: "46000.0", Last: "46000.0", Volume: "800.5", VWAP: "45900.0",
+			Trades: 9000, Low: "45000.0", High: "47000.0", Open: "45500.0",
+		}},
+		{Name: "SOLUSD", AltName: "SOLUSD", WSName: "SOL/USD", Base: "SOL", Quote: "ZUSD", Ticker: Ticker{
+			Ask: "150.01", Bid: "150.00", Last: "150.00", Volume: "90000.0", VWAP: "148.50",
+			Trades: 7000, Low: "145.00", High: "155.00", Open: "147.00",
+		}},
+	}
+}
+
+// New crée un faux serveur avec les paires de DefaultPairs
+func New() *Server {
+	return NewWithPairs(DefaultPairs()...)
+}
+
+// NewWithPairs crée un faux serveur ne connaissant que les paires données
+func NewWithPairs(pairs ...Pair) *Server {
+	s := &Server{
+		pairs:  make(map[string]*pairState),
+		now:    time.Now,
+		faults: make(map[string][]Fault),
+		calls:  make(map[string]int),
+	}
+	for _, p := range pairs {
+		s.AddPair(p)
+	}
+	return s
+}
+
+// AddPair ajoute une paire, ou la remplace si son nom interne existe déjà
+func (s *Server) AddPair(p Pair) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pairs[p.Name]; !ok {
+		s.order = append(s.order, p.Name)
+	}
+	s.pairs[p.Name] = &pairState{Pair: p, candles: make(map[int][]Candle)}
+}
+
+// RemovePair retire une paire du catalogue (délistage)
+func (s *Server) RemovePair(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.lookup(name)
+	if state == nil {
+		return
+	}
+	delete(s.pairs, state.Name)
+	for i, n := range s.order {
+		if n == state.Name {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// SetTime fixe l'heure renvoyée par Time et utilisée pour générer les bougies et le carnet
+func (s *Server) SetTime(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = func() time.Time { return t }
+}
+
+// SetTicker fixe le ticker d'une paire et annule les tickers scriptés
+func (s *Server) SetTicker(pair string, ticker Ticker) {
+	s.withPair(pair, func(state *pairState) {
+		state.Ticker = ticker
+		state.script = nil
+	})
+}
+
+// SetPrice fixe le bid, l'ask et le dernier prix d'une paire
+func (s *Server) SetPrice(pair, price string) {
+	s.withPair(pair, func(state *pairState) {
+		state.Ticker = withPrice(state.Ticker, price)
+		state.script = nil
+	})
+}
+
+// ScriptTickers programme les tickers renvoyés par les prochains appels Ticker, un par
+// appel ; le dernier reste ensuite en place
+func (s *Server) ScriptTickers(pair string, tickers ...Ticker) {
+	s.withPair(pair, func(state *pairState) {
+		state.script = append([]Ticker(nil), tickers...)
+	})
+}
+
+// ScriptPrices programme les prix (bid, ask et dernier prix) des prochains appels Ticker
+func (s *Server) ScriptPrices(pair string, prices ...string) {
+	s.withPair(pair, func(state *pairState) {
+		base := state.Ticker
+		if len(state.script) > 0 {
+			base = state.script[len(state.script)-1]
+		}
+		state.script = nil
+		for _, price := range prices {
+			state.script = append(state.script, withPrice(base, price))
+		}
+	})
+}
+
+// SetCandles fixe les bougies d'une paire pour un intervalle (en minutes)
+func (s *Server) SetCandles(pair string, intervalMinutes int, candles []Candle) {
+	s.withPair(pair, func(state *pairState) {
+		state.candles[intervalMinutes] = append([]Candle(nil), candles...)
+	})
+}
+
+// AddTrades ajoute des trades à une paire (dans l'ordre chronologique)
+func (s *Server) AddTrades(pair string, trades ...Trade) {
+	s.withPair(pair, func(state *pairState) {
+		state.trades = append(state.trades, trades...)
+	})
+}
+
+// SetBook fixe le carnet d'ordres d'une paire (asks croissants, bids décroissants)
+func (s *Server) SetBook(pair string, asks, bids []Level) {
+	s.withPair(pair, func(state *pairState) {
+		state.asks = append([]Level(nil), asks...)
+		state.bids = append([]Level(nil), bids...)
+	})
+}
+
+// FailNext fait échouer les prochains appels d'un endpoint (Ticker, OHLC...), une erreur
+// par appel. FailNext("Ticker", RateLimited, RateLimited) simule deux refus successifs.
+func (s *Server) FailNext(endpoint string, faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[endpoint] = append(s.faults[endpoint], faults...)
+}
+
+// Calls retourne le nombre d'appels reçus par un endpoint, erreurs injectées comprises
+func (s *Server) Calls(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[endpoint]
+}
+
+// withPair applique f à une paire connue ; une paire inconnue est une erreur de script
+func (s *Server) withPair(pair string, f func(*pairState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.lookup(pair)
+	if state == nil {
+		panic(fmt.Sprintf("krakenfake: paire inconnue %q", pair))
+	}
+	f(state)
+}
+
+// lookup retrouve une paire par nom interne, alternatif ou WebSocket (verrou tenu)
+func (s *Server) lookup(name string) *pairState {
+	if state, ok := s.pairs[name]; ok {
+		return state
+	}
+	for _, state := range s.pairs {
+		if strings.EqualFold(state.AltName, name) || strings.EqualFold(state.Name, name) || strings.EqualFold(state.WSName, name) {
+			return state
+		}
+	}
+	return nil
+}
+
+// withPrice retourne ticker avec bid, ask et dernier prix égaux à price
+func withPrice(ticker Ticker, price string) Ticker {
+	ticker.Ask, ticker.Bid, ticker.Last = price, price, price
+	return ticker
+}
+
+// ServeHTTP répond aux endpoints publics /0/public/<Endpoint>
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.TrimPrefix(r.URL.Path, "/0/public/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[endpoint]++
+
+	if queued := s.faults[endpoint]; len(queued) > 0 {
+		s.faults[endpoint] = queued[1:]
+		writeFault(w, queued[0])
+		return
+	}
+
+	var result interface{}
+	var fault *Fault
+	query := r.URL.Query()
+	switch endpoint {
+	case "Time":
+		now := s.now().UTC()
+		result = map[string]interface{}{"unixtime": now.Unix(), "rfc1123": now.Format("Mon, 02 Jan 06 15:04:05 -0700")}
+	case "Assets":
+		result = s.assets()
+	case "AssetPairs":
+		result = s.assetPairs()
+	case "Ticker":
+		result, fault = s.ticker(query.Get("pair"))
+	case "OHLC":
+		result, fault = s.ohlc(query.Get("pair"), query.Get("interval"), query.Get("since"))
+	case "Trades":
+		result, fault = s.tradesResult(query.Get("pair"), query.Get("since"))
+	case "Depth":
+		result, fault = s.depth(query.Get("pair"), query.Get("count"))
+	default:
+		writeFault(w, Fault{Status: http.StatusNotFound, Messages: []string{"EGeneral:Unknown method"}})
+		return
+	}
+	if fault != nil {
+		writeFault(w, *fault)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": []string{}, "result": result})
+}
+
+// writeFault écrit une réponse d'erreur au format Kraken
+func writeFault(w http.ResponseWriter, fault Fault) {
+	w.Header().Set("Content-Type", "application/json")
+	if fault.Status != 0 && fault.Status != http.StatusOK {
+		w.WriteHeader(fault.Status)
+	}
+	messages := fault.Messages
+	if messages == nil {
+		messages = []string{}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": messages})
+}
+
+// assetAltName retire le préfixe X ou Z des noms d'actifs historiques (XXBT → XBT)
+func assetAltName(name string) string {
+	if len(name) == 4 && (name[0] == 'X' || name[0] == 'Z') {
+		return name[1:]
+	}
+	return name
+}
+
+func (s *Server) assets() map[string]interface{} {
+	assets := make(map[string]interface{})
+	for _, name := range s.order {
+		p := s.pairs[name]
+		for _, asset := range []string{p.Base, p.Quote} {
+			decimals := 10
+			if asset[0] == 'Z' {
+				decimals = 4
+			}
+			assets[asset] = map[string]interface{}{
+				"aclass":           "currency",
+				"altname":          assetAltName(asset),
+				"decimals":         decimals,
+				"display_decimals": decimals / 2,
+				"status":           "enabled",
+			}
+		}
+	}
+	return assets
+}
+
+func (s *Server) assetPairs() map[string]interface{} {
+	pairs := make(map[string]interface{})
+	for _, name := range s.order {
+		p := s.pairs[name]
+		pairs[name] = map[string]interface{}{
+			"altname":             p.AltName,
+			"wsname":              p.WSName,
+			"aclass_base":         "currency",
+			"base":                p.Base,
+			"aclass_quote":        "currency",
+			"quote":               p.Quote,
+			"pair_decimals":       decimalPlaces(p.Ticker.Last),
+			"cost_decimals":       5,
+			"lot_decimals":        8,
+			"lot_multiplier":      1,
+			"leverage_buy":        []int{},
+			"leverage_sell":       []int{},
+			"fees":                [][2]json.Number{{"0", "0.26"}, {"50000", "0.24"}},
+			"fees_maker":          [][2]json.Number{{"0", "0.16"}, {"50000", "0.14"}},
+			"fee_volume_currency": "ZUSD",
+			"margin_call":         80,
+			"margin_stop":         40,
+			"ordermin":            "0.0001",
+			"costmin":             "0.5",
+			"tick_size":           tickSize(decimalPlaces(p.Ticker.Last)),
+			"status":              "online",
+		}
+	}
+	return pairs
+}
+
+// decimalPlaces retourne le nombre de décimales d'un prix ("50000.1" → 1)
+func decimalPlaces(price string) int {
+	if i := strings.IndexByte(price, '.'); i >= 0 {
+		return len(price) - i - 1
+	}
+	return 0
+}
+
+// tickSize retourne le pas de prix correspondant à un nombre de décimales (2 → "0.01")
+func tickSize(decimals int) string {
+	if decimals == 0 {
+		return "1"
+	}
+	return "0." + strings.Repeat("0", decimals-1) + "1"
+}
+
+// resolvePairs retrouve les paires d'un paramètre pair (noms séparés par des virgules)
+func (s *Server) resolvePairs(param string) ([]*pairState, *Fault) {
+	if param == "" {
+		return nil, &InvalidQuery
+	}
+	var states []*pairState
+	for _, name := range strings.Split(param, ",") {
+		state := s.lookup(strings.TrimSpace(name))
+		if state == nil {
+			return nil, &UnknownPair
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// ticker répond comme Kraken : sans paramètre pair, les tickers de toutes les paires
+func (s *Server) ticker(param string) (interface{}, *Fault) {
+	var states []*pairState
+	if param == "" {
+		for _, name := range s.order {
+			states = append(states, s.pairs[name])
+		}
+	} else {
+		var fault *Fault
+		if states, fault = s.resolvePairs(param); fault != nil {
+			return nil, fault
+		}
+	}
+	result := make(map[string]interface{})
+	for _, state := range states {
+		if len(state.script) > 0 {
+			state.Ticker = state.script[0]
+			state.script = state.script[1:]
+		}
+		t := state.Ticker
+		result[state.Name] = map[string]interface{}{
+			"a": []string{t.Ask, "1", "1.000"},
+			"b": []string{t.Bid, "1", "1.000"},
+			"c": []string{t.Last, "0.01000000"},
+			"v": []string{t.Volume, t.Volume},
+			"p": []string{t.VWAP, t.VWAP},
+			"t": []int{t.Trades, t.Trades},
+			"l": []string{t.Low, t.Low},
+			"h": []string{t.High, t.High},
+			"o": t.Open,
+		}
+	}
+	return result, nil
+}
+
+func (s *Server) ohlc(param, intervalParam, sinceParam string) (interface{}, *Fault) {
+	states, fault := s.resolvePairs(param)
+	if fault != nil {
+		return nil, fault
+	}
+	interval := 1
+	if intervalParam != "" {
+		var err error
+		if interval, err = strconv.Atoi(intervalParam); err != nil {
+			return nil, &InvalidQuery
+		}
+	}
+	switch interval {
+	case 1, 5, 15, 30, 60, 240, 1440, 10080, 21600:
+	default:
+		return nil, &InvalidQuery
+	}
+	since, _ := strconv.ParseInt(sinceParam, 10, 64)
+
+	state := states[0]
+	candles, ok := state.candles[interval]
+	if !ok {
+		candles = s.syntheticCandles(state, interval)
+	}
+
+	rows := [][]interface{}{}
+	last := since
+	for _, c := range candles {
+		if c.Time <= since {
+			continue
+		}
+		rows = append(rows, []interface{}{c.Time, c.Open, c.High, c.Low, c.Close, c.VWAP, c.Volume, c.Count})
+		last = c.Time
+	}
+	// Comme Kraken, ne renvoyer que les bougies les plus récentes
+	if len(rows) > MaxCandles {
+		rows = rows[len(rows)-MaxCandles:]
+	}
+	return map[string]interface{}{state.Name: rows, "last": last}, nil
+}
+
+// syntheticCandles génère des bougies plates au dernier prix, la plus récente étant
+// celle de l'intervalle en cours
+func (s *Server) syntheticCandles(state *pairState, interval int) []Candle {
+	step := int64(interval * 60)
+	end := s.now().Unix() / step * step
+	candles := make([]Candle, syntheticCandles)
+	for i := range candles {
+		price := state.Ticker.Last
+		candles[i] = Candle{
+			Time: end - int64(syntheticCandles-1-i)*step, Open: price, High: price, Low: price, Close: price,
+			VWAP: price, Volume: "1.00000000", Count: 1,
+		}
+	}
+	return candles
+}
+
+func (s *Server) tradesResult(param, sinceParam string) (interface{}, *Fault) {
+	states, fault := s.resolvePairs(param)
+	if fault != nil {
+		return nil, fault
+	}
+	// Le curseur since est en nanosecondes
+	since, _ := strconv.ParseInt(sinceParam, 10, 64)
+	state := states[0]
+
+	rows := [][]interface{}{}
+	last := since
+	for _, t := range state.trades {
+		ns := int64(t.Time * 1e9)
+		if ns <= since {
+			continue
+		}
+		if len(rows) == MaxTrades {
+			break
+		}
+		rows = append(rows, []interface{}{t.Price, t.Volume, t.Time, t.Side, t.OrderType, "", t.ID})
+		last = ns
+	}
+	return map[string]interface{}{state.Name: rows, "last": strconv.FormatInt(last, 10)}, nil
+}
+
+func (s *Server) depth(param, countParam string) (interface{}, *Fault) {
+	states, fault := s.resolvePairs(param)
+	if fault != nil {
+		return nil, fault
+	}
+	count := 100
+	if countParam != "" {
+		var err error
+		if count, err = strconv.Atoi(countParam); err != nil || count < 1 {
+			return nil, &InvalidQuery
+		}
+	}
+	state := states[0]
+	asks, bids := state.asks, state.bids
+	if asks == nil && bids == nil {
+		asks, bids = s.syntheticBook(state)
+	}
+	return map[string]interface{}{state.Name: map[string]interface{}{
+		"asks": levelRows(asks, count),
+		"bids": levelRows(bids, count),
+	}}, nil
+}
+
+// syntheticBook génère un carnet régulier à partir du bid et de l'ask, un pas de prix
+// par niveau
+func (s *Server) syntheticBook(state *pairState) (asks, bids []Level) {
+	decimals := decimalPlaces(state.Ticker.Ask)
+	step, _ := strconv.ParseFloat(tickSize(decimals), 64)
+	ask, _ := strconv.ParseFloat(state.Ticker.Ask, 64)
+	bid, _ := strconv.ParseFloat(state.Ticker.Bid, 64)
+	now := s.now().Unix()
+	for i := 0; i < syntheticLevels; i++ {
+		volume := strconv.FormatFloat(float64(i+1)*0.5, 'f', 3, 64)
+		asks = append(asks, Level{Price: strconv.FormatFloat(ask+float64(i)*step, 'f', decimals, 64), Volume: volume, Time: now})
+		bids = append(bids, Level{Price: strconv.FormatFloat(bid-float64(i)*step, 'f', decimals, 64), Volume: volume, Time: now})
+	}
+	return asks, bids
+}
+
+// levelRows encode au plus count niveaux au format Kraken [prix, volume, timestamp]
+func levelRows(levels []Level, count int) [][]interface{} {
+	rows := [][]interface{}{}
+	for i, l := range levels {
+		if i == count {
+			break
+		}
+		rows = append(rows, []interface{}{l.Price, l.Volume, l.Time})
+	}
+	return rows
+}
+
+// Pairs retourne les noms internes des paires du catalogue, triés
+func (s *Server) Pairs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := append([]string(nil), s.order...)
+	sort.Strings(names)
+	return names
+}
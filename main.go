@@ -1,16 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"encoding/csv"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -29,38 +32,84 @@ type ServerTime struct {
 }
 
 // GetServerStatus récupère le statut et le timing du serveur Kraken
-func GetServerStatus() (*ServerTime, error) {
-	url := "https://api.kraken.com/0/public/Time"
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
+func (c *KrakenClient) GetServerStatus(ctx context.Context) (*ServerTime, error) {
 	var response struct {
 		Error  []string   `json:"error"`
 		Result ServerTime `json:"result"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+	if err := c.get(ctx, "/0/public/Time", nil, &response); err != nil {
 		return nil, err
 	}
-	if len(response.Error) > 0 {
-		return nil, fmt.Errorf("API Time error: %v", response.Error)
-	}
 	return &response.Result, nil
 }
 
 // Structure pour récupérer les Asset Pairs
+// (métadonnées complètes renvoyées par l'endpoint AssetPairs, stockées dans la table pairs)
 type AssetPair struct {
-	AltName string `json:"altname"`
-	WSName  string `json:"wsname"`
+	Name              string    `json:"name"` // Nom interne (clé de la réponse Kraken)
+	AltName           string    `json:"altname"`
+	WSName            string    `json:"wsname"`
+	AClassBase        string    `json:"aclass_base"`
+	Base              string    `json:"base"`
+	AClassQuote       string    `json:"aclass_quote"`
+	Quote             string    `json:"quote"`
+	PairDecimals      int       `json:"pair_decimals"` // Décimales des prix
+	CostDecimals      int       `json:"cost_decimals"`
+	LotDecimals       int       `json:"lot_decimals"` // Décimales des volumes
+	LotMultiplier     int       `json:"lot_multiplier"`
+	LeverageBuy       []int     `json:"leverage_buy"`
+	LeverageSell      []int     `json:"leverage_sell"`
+	Fees              []FeeTier `json:"fees"`
+	FeesMaker         []FeeTier `json:"fees_maker"`
+	FeeVolumeCurrency string    `json:"fee_volume_currency"`
+	MarginCall        int       `json:"margin_call"`
+	MarginStop        int       `json:"margin_stop"`
+	OrderMin          Decimal   `json:"ordermin"`  // Volume minimal d'un ordre
+	CostMin           Decimal   `json:"costmin"`   // Coût minimal d'un ordre (en devise de cotation)
+	TickSize          Decimal   `json:"tick_size"` // Pas de prix
+	Status            string    `json:"status"`    // online, cancel_only, post_only, limit_only, reduce_only
+	UpdatedAt         string    `json:"updated_at,omitempty"`
+
+	// Forme canonique renseignée par l'API à partir du catalogue des actifs (BTC/USD)
+	Symbol      string `json:"symbol,omitempty"`
+	BaseSymbol  string `json:"base_symbol,omitempty"`
+	QuoteSymbol string `json:"quote_symbol,omitempty"`
+}
+
+// Palier de frais : à partir de Volume (sur 30 jours), Percent % par ordre.
+// Encodé comme chez Kraken sous la forme [volume, pourcentage].
+type FeeTier struct {
+	Volume  Decimal
+	Percent Decimal
+}
+
+// UnmarshalJSON décode un palier [volume, pourcentage]
+func (f *FeeTier) UnmarshalJSON(data []byte) error {
+	var raw []json.Number
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) < 2 {
+		return fmt.Errorf("palier de frais incomplet: %s", data)
+	}
+	f.Volume = decimalFromJSON(raw[0])
+	f.Percent = decimalFromJSON(raw[1])
+	return nil
+}
+
+// MarshalJSON encode un palier sous la forme [volume, pourcentage]
+func (f FeeTier) MarshalJSON() ([]byte, error) {
+	return json.Marshal([2]Decimal{f.Volume, f.Percent})
 }
 
 // Structure pour maintenir la correspondance entre les noms de paires
 type PairMapping struct {
 	InternalName string
 	AltName      string
-	Volume       float64
+	WSName       string
+	Base         string  // Symbole usuel de l'actif de base (BTC, ETH...)
+	Quote        string  // Symbole usuel de la devise de cotation (USD, EUR...)
+	Volume       float64 // Volume sur 24h dans la devise de référence, renseigné par le classement
 }
 
 // Structure pour stocker une paire et son volume
@@ -69,123 +118,184 @@ type PairVolume struct {
 	Volume float64
 }
 
-// GetTopVolumeAssetPairs récupère les paires avec le plus grand volume d'échanges
-func GetTopVolumeAssetPairs(count int) ([]PairMapping, error) {
-	// 1. Récupérer toutes les paires disponibles
-	url := "https://api.kraken.com/0/public/AssetPairs"
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
+// GetAllAssetPairs récupère toutes les paires disponibles, indexées par nom interne
+func (c *KrakenClient) GetAllAssetPairs(ctx context.Context) (map[string]AssetPair, error) {
 	var response struct {
 		Error  []string             `json:"error"`
 		Result map[string]AssetPair `json:"result"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+	if err := c.get(ctx, "/0/public/AssetPairs", nil, &response); err != nil {
 		return nil, err
 	}
-	if len(response.Error) > 0 {
-		return nil, fmt.Errorf("API AssetPairs error: %v", response.Error)
+	for name, pair := range response.Result {
+		pair.Name = name
+		response.Result[name] = pair
 	}
+	return response.Result, nil
+}
 
-	// 2. Préparer des batchs de paires pour les requêtes Ticker et garder la correspondance
-	var pairsMapping []PairMapping
-
-	for internalName, pair := range response.Result {
-		if pair.AltName != "" {
-			pairsMapping = append(pairsMapping, PairMapping{
-				InternalName: internalName,
-				AltName:      pair.AltName,
-				Volume:       0,
-			})
-		}
-	}
+// GetTopVolumeAssetPairs récupère les paires avec le plus grand volume d'échanges,
+// converti en dollars
+func (c *KrakenClient) GetTopVolumeAssetPairs(ctx context.Context, count int) ([]PairMapping, error) {
+	return c.SelectAssetPairs(ctx, PairSelection{Top: count})
+}
 
-	// 3. Faire des requêtes par lots pour éviter de surcharger l'API
-	batchSize := 10 // Kraken permet jusqu'à environ 20 paires par requête
-	for i := 0; i < len(pairsMapping); i += batchSize {
-		end := i + batchSize
-		if end > len(pairsMapping) {
-			end = len(pairsMapping)
-		}
+// GetAssetPairs retourne les paires suivies selon la politique de sélection du client.
+// La sélection est réévaluée toutes les RankingTTL pour ne pas retélécharger à chaque
+// cycle le catalogue des paires et les tickers de toutes les paires.
+// Seul un classement complet est gardé en cache : sinon il est refait au cycle suivant.
+func (c *KrakenClient) GetAssetPairs(ctx context.Context) ([]PairMapping, error) {
+	return c.selection.get(krakenExchangeName, c.RankingTTL, c.Universe, func() ([]PairMapping, error) {
+		return c.marketPairs(ctx)
+	}, func(all []PairMapping) ([]PairRanking, error) {
+		return c.fetchMarket(ctx, all)
+	})
+}
 
-		var batchNames []string
-		for j := i; j < end; j++ {
-			batchNames = append(batchNames, pairsMapping[j].InternalName)
-		}
+// Intervalle de rafraîchissement de la liste des paires des collecteurs (trades, carnet d'ordres)
+const collectorPairsRefreshInterval = 1 * time.Hour
 
-		pairParam := strings.Join(batchNames, ",")
+// Liste des paires d'un collecteur : les paires données explicitement,
+// ou à défaut celles de l'archivage, rafraîchies périodiquement
+type collectorPairs struct {
+	client      *KrakenClient
+	names       []string
+	pairs       []PairMapping
+	refreshedAt time.Time
+}
 
-		// Récupérer le ticker pour ce lot de paires
-		tickerURL := fmt.Sprintf("https://api.kraken.com/0/public/Ticker?pair=%s", pairParam)
-		tickerResp, err := http.Get(tickerURL)
-		if err != nil {
-			log.Printf("Erreur lors de la récupération des tickers: %v", err)
-			continue
-		}
+// Get retourne la liste des paires, rafraîchie si elle est trop ancienne.
+// En cas d'erreur, la liste précédente est conservée.
+func (c *collectorPairs) Get(ctx context.Context) []PairMapping {
+	if c.pairs != nil && time.Since(c.refreshedAt) < collectorPairsRefreshInterval {
+		return c.pairs
+	}
 
-		var tickerResponse TickerResponse
-		if err := json.NewDecoder(tickerResp.Body).Decode(&tickerResponse); err != nil {
-			tickerResp.Body.Close()
-			log.Printf("Erreur lors du décodage des tickers: %v", err)
-			continue
-		}
-		tickerResp.Body.Close()
+	var pairs []PairMapping
+	var err error
+	if len(c.names) > 0 {
+		pairs, err = c.client.ResolveAssetPairs(ctx, c.names)
+	} else {
+		pairs, err = c.client.GetAssetPairs(ctx)
+	}
+	if err != nil {
+		log.Println("Erreur récupération des paires du collecteur:", err)
+		return c.pairs
+	}
+	c.pairs = pairs
+	c.refreshedAt = time.Now()
+	return c.pairs
+}
 
-		if len(tickerResponse.Error) > 0 {
-			log.Printf("API Ticker error: %v", tickerResponse.Error)
-			continue
-		}
+// ResolveAssetPairs retrouve les paires demandées par leur nom interne (XXBTZUSD),
+// alternatif (XBTUSD) ou une forme BASE/QUOTE (XBT/USD, BTC/USD, BTC-USD)
+func (c *KrakenClient) ResolveAssetPairs(ctx context.Context, names []string) ([]PairMapping, error) {
+	assetPairs, err := c.GetAllAssetPairs(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-		// Traiter les résultats du ticker
-		for internalName, ticker := range tickerResponse.Result {
-			volume, _ := strconv.ParseFloat(ticker.Volume[1], 64) // Volume sur 24h
+	index := make(map[string]PairSymbol)
+	for internalName, pair := range assetPairs {
+		indexPairSymbol(index, newPairSymbol(internalName, pair.AltName, pair.WSName, "", ""), pair.WSName)
+	}
 
-			// Mettre à jour le volume dans notre correspondance
-			for j := range pairsMapping {
-				if pairsMapping[j].InternalName == internalName {
-					pairsMapping[j].Volume = volume
-					break
-				}
-			}
+	var pairs []PairMapping
+	for _, name := range names {
+		ps, ok := index[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("paire inconnue: %s", name)
 		}
-
-		// Attendre un peu pour respecter les limites de l'API
-		time.Sleep(200 * time.Millisecond)
+		pair := assetPairs[ps.Name]
+		pairs = append(pairs, PairMapping{InternalName: ps.Name, AltName: pair.AltName, WSName: pair.WSName})
 	}
+	return pairs, nil
+}
 
-	// 4. Trier les paires par volume décroissant
-	sort.Slice(pairsMapping, func(i, j int) bool {
-		return pairsMapping[i].Volume > pairsMapping[j].Volume
-	})
-
-	// 5. Sélectionner les N premières paires
-	var topPairs []PairMapping
-	for i := 0; i < count && i < len(pairsMapping); i++ {
-		pair := pairsMapping[i]
-		topPairs = append(topPairs, pair)
-		log.Printf("Paire #%d: %s (Nom interne: %s, Volume: %.2f)",
-			i+1, pair.AltName, pair.InternalName, pair.Volume)
+// Tableau de valeurs du ticker. Kraken mélange chaînes et nombres selon l'API
+// (le volume de lot entier est une chaîne en REST, un nombre en WebSocket) :
+// tout est conservé sous forme de chaîne, sans perte de précision.
+type KrakenValues []string
+
+func (v *KrakenValues) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return err
+	}
+	// Valeur isolée (ouverture du jour "o" en REST) : tableau d'un élément
+	raw, ok := decoded.([]interface{})
+	if !ok {
+		raw = []interface{}{decoded}
 	}
 
-	return topPairs, nil
+	values := make(KrakenValues, 0, len(raw))
+	for _, item := range raw {
+		switch value := item.(type) {
+		case string:
+			values = append(values, value)
+		case json.Number:
+			values = append(values, value.String())
+		default:
+			return fmt.Errorf("valeur inattendue dans le ticker: %v", item)
+		}
+	}
+	*v = values
+	return nil
 }
 
-// Modifier la fonction GetAssetPairs pour utiliser notre nouvelle fonction
-func GetAssetPairs() ([]PairMapping, error) {
-	return GetTopVolumeAssetPairs(20)
+// Decimal retourne la valeur d'indice i sous forme de nombre exact (vide si absente ou invalide)
+func (v KrakenValues) Decimal(i int) Decimal {
+	if i >= len(v) {
+		return ""
+	}
+	d, _ := ParseDecimal(v[i])
+	return d
 }
 
 // Structures pour récupérer les informations du Ticker
+// Chaque tableau donne la valeur de la journée en cours puis celle des dernières 24h,
+// sauf Ask et Bid (prix, volume de lot entier, volume de lot) et Last (prix, volume).
 type TickerInfo struct {
-	Ask    []string `json:"a"` // Prix de vente
-	Bid    []string `json:"b"` // Prix d'achat
-	Last   []string `json:"c"` // Dernier trade
-	Volume []string `json:"v"` // Volume sur 24h
-	High   []string `json:"h"` // Prix le plus haut sur 24h
-	Low    []string `json:"l"` // Prix le plus bas sur 24h
+	Ask    KrakenValues `json:"a"` // Prix de vente
+	Bid    KrakenValues `json:"b"` // Prix d'achat
+	Last   KrakenValues `json:"c"` // Dernier trade
+	Volume KrakenValues `json:"v"` // Volume
+	VWAP   KrakenValues `json:"p"` // Prix moyen pondéré par le volume
+	Trades KrakenValues `json:"t"` // Nombre de trades
+	High   KrakenValues `json:"h"` // Prix le plus haut
+	Low    KrakenValues `json:"l"` // Prix le plus bas
+	Open   KrakenValues `json:"o"` // Prix d'ouverture du jour (une seule valeur en REST)
+}
+
+// Tick convertit le ticker en relevé de l'historique. Volume reste le volume
+// sur 24h, High et Low ceux de la journée, comme dans les relevés existants.
+func (t *TickerInfo) Tick(pair, timestamp string) *Tick {
+	return &Tick{
+		Exchange:          krakenExchangeName,
+		Pair:              pair,
+		Ask:               t.Ask.Decimal(0),
+		AskWholeLotVolume: t.Ask.Decimal(1),
+		AskLotVolume:      t.Ask.Decimal(2),
+		Bid:               t.Bid.Decimal(0),
+		BidWholeLotVolume: t.Bid.Decimal(1),
+		BidLotVolume:      t.Bid.Decimal(2),
+		Last:              t.Last.Decimal(0),
+		LastVolume:        t.Last.Decimal(1),
+		Open:              t.Open.Decimal(0),
+		Volume:            t.Volume.Decimal(1),
+		VolumeToday:       t.Volume.Decimal(0),
+		High:              t.High.Decimal(0),
+		High24h:           t.High.Decimal(1),
+		Low:               t.Low.Decimal(0),
+		Low24h:            t.Low.Decimal(1),
+		VWAPToday:         t.VWAP.Decimal(0),
+		VWAP24h:           t.VWAP.Decimal(1),
+		TradesToday:       t.Trades.Decimal(0),
+		Trades24h:         t.Trades.Decimal(1),
+		Timestamp:         timestamp,
+	}
 }
 
 type TickerResponse struct {
@@ -193,22 +303,70 @@ type TickerResponse struct {
 	Result map[string]TickerInfo `json:"result"`
 }
 
-func GetTicker(pair string) (*TickerInfo, error) {
-	url := fmt.Sprintf("https://api.kraken.com/0/public/Ticker?pair=%s", pair)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
+// Nombre de paires par requête Ticker (Kraken permet jusqu'à environ 20 paires par requête)
+const tickerBatchSize = 10
+
+// GetTickers récupère les tickers de plusieurs paires par lots, indexés par nom interne.
+// Si un lot échoue, les autres sont tout de même retournés avec la dernière erreur rencontrée.
+// Kraken refusant tout le lot pour une seule paire inconnue, un lot en erreur permanente
+// est réinterrogé paire par paire : seule la paire fautive est écartée.
+// Sans liste de paires, les tickers de tout le catalogue sont récupérés en une requête.
+func (c *KrakenClient) GetTickers(ctx context.Context, pairs []string) (map[string]TickerInfo, error) {
+	tickers := make(map[string]TickerInfo)
+	if len(pairs) == 0 {
+		return tickers, c.getTickerBatch(ctx, nil, tickers)
+	}
+	var lastErr error
+
+	for i := 0; i < len(pairs); i += tickerBatchSize {
+		end := i + tickerBatchSize
+		if end > len(pairs) {
+			end = len(pairs)
+		}
+
+		batch := pairs[i:end]
+		err := c.getTickerBatch(ctx, batch, tickers)
+		if len(batch) > 1 && IsKrakenErrorKind(err, ErrPermanent) {
+			log.Printf("Lot de %d tickers refusé (%v), interrogation paire par paire", len(batch), err)
+			err = nil
+			for _, pair := range batch {
+				if pairErr := c.getTickerBatch(ctx, []string{pair}, tickers); pairErr != nil {
+					log.Printf("Ticker de %s ignoré: %v", pair, pairErr)
+					err = pairErr
+				}
+			}
+		}
+		if err != nil {
+			lastErr = err
+		}
 	}
-	defer resp.Body.Close()
+	return tickers, lastErr
+}
 
+// getTickerBatch récupère les tickers d'un lot de paires en une requête et les ajoute à tickers
+// (toutes les paires si le lot est vide)
+func (c *KrakenClient) getTickerBatch(ctx context.Context, batch []string, tickers map[string]TickerInfo) error {
+	var params url.Values
+	if len(batch) > 0 {
+		params = url.Values{"pair": {strings.Join(batch, ",")}}
+	}
 	var tickerResp TickerResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tickerResp); err != nil {
-		return nil, err
+	if err := c.get(ctx, "/0/public/Ticker", params, &tickerResp); err != nil {
+		return err
+	}
+	for internalName, info := range tickerResp.Result {
+		tickers[internalName] = info
 	}
-	if len(tickerResp.Error) > 0 {
-		return nil, fmt.Errorf("API Ticker error: %v", tickerResp.Error)
+	return nil
+}
+
+// GetTicker récupère le ticker d'une paire
+func (c *KrakenClient) GetTicker(ctx context.Context, pair string) (*TickerInfo, error) {
+	tickers, err := c.GetTickers(ctx, []string{pair})
+	if err != nil {
+		return nil, err
 	}
-	for _, info := range tickerResp.Result {
+	for _, info := range tickers {
 		return &info, nil
 	}
 	return nil, fmt.Errorf("No ticker data found for %s", pair)
@@ -216,57 +374,70 @@ func GetTicker(pair string) (*TickerInfo, error) {
 
 // ------------------- Partie Base de Données SQLite -------------------
 
-// InitDB ouvre (ou crée) la base SQLite et crée la table si nécessaire.
+// InitDB ouvre (ou crée) la base SQLite et applique les migrations en attente.
 func InitDB(dbPath string) *sql.DB {
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	createTableQuery := `
-	CREATE TABLE IF NOT EXISTS crypto_data (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		pair TEXT UNIQUE,
-		ask_price REAL,
-		bid_price REAL,
-		last_trade_price REAL,
-		volume REAL,
-		high_price REAL,
-		low_price REAL,
-		timestamp DATETIME
-	);`
-	if _, err = db.Exec(createTableQuery); err != nil {
+	if err := MigrateDB(db); err != nil {
 		log.Fatal(err)
 	}
 	return db
 }
 
-// CleanDB vide la base pour repartir sur des données réelles uniquement.
-func CleanDB(db *sql.DB) {
-	_, err := db.Exec("DELETE FROM crypto_data")
+// ResetFilter restreint le nettoyage à une paire et/ou une plage de temps.
+// Un champ vide (ou une date nulle) ne filtre pas.
+type ResetFilter struct {
+	Pair string
+	From time.Time
+	To   time.Time
+}
+
+// CleanDB supprime les relevés correspondant au filtre et retourne le nombre de lignes supprimées.
+// Sans filtre, toute la base est vidée.
+func CleanDB(ctx context.Context, db *sql.DB, filter ResetFilter) (int64, error) {
+	query := "DELETE FROM crypto_history"
+	var conditions []string
+	var args []interface{}
+
+	if filter.Pair != "" {
+		conditions = append(conditions, "pair = ?")
+		args = append(args, filter.Pair)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, filter.From.UTC().Format(time.RFC3339))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "timestamp <= ?")
+		args = append(args, filter.To.UTC().Format(time.RFC3339))
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
 	if err != nil {
-		log.Println("Erreur lors du nettoyage de la base:", err)
-	} else {
-		log.Println("Base nettoyée avec succès.")
+		return 0, err
 	}
+	return result.RowsAffected()
 }
 
-// InsertCryptoData stocke les informations d'une paire dans la base.
-func InsertCryptoData(db *sql.DB, pair string, ask, bid, lastTrade, volume, high, low float64, timestamp string) {
-	query := `INSERT INTO crypto_data (pair, ask_price, bid_price, last_trade_price, volume, high_price, low_price, timestamp) 
-	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	          ON CONFLICT(pair) DO UPDATE SET 
-	            ask_price=excluded.ask_price, 
-	            bid_price=excluded.bid_price, 
-	            last_trade_price=excluded.last_trade_price,
-	            volume=excluded.volume,
-	            high_price=excluded.high_price,
-	            low_price=excluded.low_price,
-	            timestamp=excluded.timestamp;`
+// InsertCryptoData ajoute un relevé d'une paire à l'historique.
+// Les prix et volumes sont stockés en texte, sans perte de précision.
+func InsertCryptoData(ctx context.Context, db *sql.DB, tick *Tick) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tickColumns)), ", ")
+	query := fmt.Sprintf("INSERT INTO crypto_history (%s) VALUES (%s);", tickSelectColumns(), placeholders)
 
-	_, err := db.Exec(query, pair, ask, bid, lastTrade, volume, high, low, timestamp)
+	var args []interface{}
+	for _, c := range tickColumns {
+		args = append(args, c.value(tick))
+	}
+	_, err := db.ExecContext(ctx, query, args...)
 	if err != nil {
-		log.Println("Erreur lors de l'insertion des données pour", pair, ":", err)
+		log.Println("Erreur lors de l'insertion des données pour", tick.Pair, ":", err)
 	}
 }
 
@@ -292,16 +463,15 @@ func initCSVDirectory() string {
 	return csvDir
 }
 
-// ExportAllPairsToSingleCSV exporte toutes les paires vers un seul fichier CSV
-func ExportAllPairsToSingleCSV(db *sql.DB) (string, error) {
+// ExportAllPairsToSingleCSV exporte le dernier relevé de toutes les paires vers un seul fichier CSV.
+// En cas d'erreur (annulation comprise), le fichier incomplet est supprimé.
+func ExportAllPairsToSingleCSV(ctx context.Context, db *sql.DB) (_ string, err error) {
 	csvDir := initCSVDirectory()
 	filename := generateCSVFilename()
 	filePath := filepath.Join(csvDir, filename)
 
 	// Récupérer les données de toutes les paires
-	rows, err := db.Query(
-		"SELECT pair, ask_price, bid_price, last_trade_price, volume, high_price, low_price, timestamp FROM crypto_data",
-	)
+	rows, err := db.QueryContext(ctx, "SELECT "+tickSelectColumns()+" FROM crypto_latest")
 	if err != nil {
 		return "", err
 	}
@@ -313,54 +483,52 @@ func ExportAllPairsToSingleCSV(db *sql.DB) (string, error) {
 		return "", err
 	}
 	defer file.Close()
+	defer removeOnError(filePath, &err)
 
 	writer := csv.NewWriter(file)
-	defer writer.Flush()
 
 	// Écrire l'en-tête
-	headers := []string{"Pair", "Ask", "Bid", "Last", "Volume", "High", "Low", "Timestamp"}
-	if err := writer.Write(headers); err != nil {
+	if err := writer.Write(tickCSVHeaders(tickColumns)); err != nil {
 		return "", err
 	}
 
-	// Écrire les données pour toutes les paires
+	// Écrire les données pour toutes les paires, avec la précision fournie par Kraken
 	for rows.Next() {
-		var pair string
-		var ask, bid, lastTrade, volume, high, low float64
-		var timestamp string
-		if err := rows.Scan(&pair, &ask, &bid, &lastTrade, &volume, &high, &low, &timestamp); err != nil {
+		var tick Tick
+		if err := scanTick(rows, &tick); err != nil {
 			return "", err
 		}
-
-		record := []string{
-			pair,
-			strconv.FormatFloat(ask, 'f', 8, 64),      // Augmenté de 4 à 8 décimales
-			strconv.FormatFloat(bid, 'f', 8, 64),      // Augmenté de 4 à 8 décimales
-			strconv.FormatFloat(lastTrade, 'f', 8, 64), // Augmenté de 4 à 8 décimales
-			strconv.FormatFloat(volume, 'f', 4, 64),    // Volume reste à 4 décimales car c'est moins critique
-			strconv.FormatFloat(high, 'f', 8, 64),      // Augmenté de 4 à 8 décimales
-			strconv.FormatFloat(low, 'f', 8, 64),       // Augmenté de 4 à 8 décimales
-			timestamp,
-		}
-
-		if err := writer.Write(record); err != nil {
+		if err := writer.Write(tickCSVRecord(&tick, tickColumns)); err != nil {
 			return "", err
 		}
 	}
+	// Lecture interrompue (annulation) : ne pas laisser un export tronqué
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
 
+	// De même si l'écriture des dernières lignes en tampon échoue (disque plein)
+	writer.Flush()
+	if err = writer.Error(); err != nil {
+		return "", err
+	}
 	return filename, nil
 }
 
-// ExportPairToCSV exporte les données d'une paire vers un fichier CSV
-func ExportPairToCSV(db *sql.DB, pair string) (string, error) {
+// ExportPairToCSV exporte l'historique d'une paire vers un fichier CSV, limité aux colonnes
+// demandées et à un exchange (tous si exchange est vide)
+func ExportPairToCSV(ctx context.Context, db *sql.DB, exchange, pair string, columns []tickColumn) (_ string, err error) {
 	csvDir := initCSVDirectory()
 	filename := fmt.Sprintf("%s_%s", pair, generateCSVFilename())
+	if exchange != "" {
+		filename = fmt.Sprintf("%s_%s_%s", exchange, pair, generateCSVFilename())
+	}
 	filePath := filepath.Join(csvDir, filename)
 
-	// Récupérer les données de la paire
-	rows, err := db.Query(
-		"SELECT pair, ask_price, bid_price, last_trade_price, volume, high_price, low_price, timestamp FROM crypto_data WHERE pair = ?",
-		pair,
+	// Récupérer tout l'historique de la paire
+	rows, err := db.QueryContext(ctx,
+		"SELECT "+tickSelectColumns()+" FROM crypto_history WHERE pair = ? AND (? = '' OR exchange = ?) ORDER BY timestamp, exchange",
+		pair, exchange, exchange,
 	)
 	if err != nil {
 		return "", err
@@ -373,41 +541,96 @@ func ExportPairToCSV(db *sql.DB, pair string) (string, error) {
 		return "", err
 	}
 	defer file.Close()
+	defer removeOnError(filePath, &err)
 
 	writer := csv.NewWriter(file)
-	defer writer.Flush()
 
 	// Écrire l'en-tête
-	headers := []string{"Pair", "Ask", "Bid", "Last", "Volume", "High", "Low", "Timestamp"}
-	if err := writer.Write(headers); err != nil {
+	if err := writer.Write(tickCSVHeaders(columns)); err != nil {
 		return "", err
 	}
 
 	// Écrire les données
 	for rows.Next() {
-		var pair string
-		var ask, bid, lastTrade, volume, high, low float64
-		var timestamp string
-		if err := rows.Scan(&pair, &ask, &bid, &lastTrade, &volume, &high, &low, &timestamp); err != nil {
+		var tick Tick
+		if err := scanTick(rows, &tick); err != nil {
 			return "", err
 		}
+		if err := writer.Write(tickCSVRecord(&tick, columns)); err != nil {
+			return "", err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	writer.Flush()
+	if err = writer.Error(); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// removeOnError supprime un fichier d'export si la fonction appelante a échoué
+func removeOnError(filePath string, err *error) {
+	if *err != nil {
+		os.Remove(filePath)
+	}
+}
+
+// ExportCandlesToCSV exporte les bougies OHLC d'une paire sur un exchange vers un fichier CSV
+func ExportCandlesToCSV(ctx context.Context, db *sql.DB, exchange, pair, interval string, from, to time.Time) (_ string, err error) {
+	duration, err := parseCandleInterval(interval)
+	if err != nil {
+		return "", err
+	}
+	candles, err := GetCandles(ctx, db, exchange, pair, duration, from, to)
+	if err != nil {
+		return "", err
+	}
+
+	csvDir := initCSVDirectory()
+	filename := fmt.Sprintf("%s_candles_%s_%s", pair, interval, generateCSVFilename())
+	filePath := filepath.Join(csvDir, filename)
+
+	// Créer le fichier CSV
+	file, err := os.Create(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	defer removeOnError(filePath, &err)
 
+	writer := csv.NewWriter(file)
+
+	// Écrire l'en-tête
+	headers := []string{"Pair", "Time", "Open", "High", "Low", "Close", "Volume", "Ticks"}
+	if err := writer.Write(headers); err != nil {
+		return "", err
+	}
+
+	for _, c := range candles {
 		record := []string{
 			pair,
-			strconv.FormatFloat(ask, 'f', 8, 64),       // Augmenté à 8 décimales
-			strconv.FormatFloat(bid, 'f', 8, 64),       // Augmenté à 8 décimales
-			strconv.FormatFloat(lastTrade, 'f', 8, 64), // Augmenté à 8 décimales
-			strconv.FormatFloat(volume, 'f', 4, 64),    // Volume reste à 4 décimales
-			strconv.FormatFloat(high, 'f', 8, 64),      // Augmenté à 8 décimales
-			strconv.FormatFloat(low, 'f', 8, 64),       // Augmenté à 8 décimales
-			timestamp,
+			c.Time,
+			c.Open.String(),
+			c.High.String(),
+			c.Low.String(),
+			c.Close.String(),
+			c.Volume.String(),
+			strconv.Itoa(c.Ticks),
 		}
-
 		if err := writer.Write(record); err != nil {
 			return "", err
 		}
 	}
 
+	// Les dernières lignes ne sont écrites qu'au vidage du tampon : son échec (disque plein)
+	// laisserait un fichier tronqué
+	writer.Flush()
+	if err = writer.Error(); err != nil {
+		return "", err
+	}
 	return filename, nil
 }
 
@@ -418,16 +641,26 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Crypto Archive API\n")
 	fmt.Fprintf(w, "Routes disponibles:\n")
 	fmt.Fprintf(w, "- GET /api/status : Statut du serveur\n")
+	fmt.Fprintf(w, "- GET /api/metrics : Métriques internes (limiteur de débit Kraken)\n")
 	fmt.Fprintf(w, "- GET /api/pairs : Liste des paires disponibles\n")
+	fmt.Fprintf(w, "- GET /api/pairs/<pair> : Métadonnées d'une paire (décimales, minimums, frais, statut)\n")
+	fmt.Fprintf(w, "  <pair> accepte toutes les formes : XXBTZUSD, XBTUSD, BTC/USD, BTC-USD\n")
+	fmt.Fprintf(w, "- GET /api/rankings?currency=USD&quotes=&limit= : Classement des paires par volume sur 24h converti dans une devise\n")
 	fmt.Fprintf(w, "- GET /api/data/<pair> : Données pour une paire spécifique\n")
-	fmt.Fprintf(w, "- GET /api/export/<pair> : Télécharger CSV pour une paire\n")
+	fmt.Fprintf(w, "- GET /api/data/<pair>?from=&to=&limit=&order=&cursor= : Historique paginé d'une paire\n")
+	fmt.Fprintf(w, "  ?columns=pair,ask,bid,vwap_24h... : champs des relevés à retourner (tous par défaut)\n")
+	fmt.Fprintf(w, "  ?exchange=kraken|binance|coinbase : relevés d'un seul exchange (tous par défaut)\n")
+	fmt.Fprintf(w, "- GET /api/candles/<pair>?interval=1m|5m|15m|1h|1d&from=&to= : Bougies OHLC d'une paire (&source=kraken|binance|coinbase pour l'historique rapatrié)\n")
+	fmt.Fprintf(w, "- GET /api/book/<pair>?at= : Carnet d'ordres archivé le plus proche d'une date\n")
+	fmt.Fprintf(w, "- GET /api/compare/<pair>?interval=5m&from=&to=&live= : Meilleurs prix entre exchanges, écart et historique de l'écart\n")
+	fmt.Fprintf(w, "- GET /api/export/<pair> : Télécharger CSV pour une paire (?interval= pour les bougies)\n")
 	fmt.Fprintf(w, "- GET /api/export-latest : Télécharger le dernier fichier CSV global\n")
 }
 
 // Gestionnaire pour le statut du serveur
-func statusHandler(db *sql.DB) http.HandlerFunc {
+func statusHandler(client *KrakenClient, db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		serverTime, err := GetServerStatus()
+		serverTime, err := client.GetServerStatus(r.Context())
 		if err != nil {
 			http.Error(w, "Erreur lors de la récupération du statut", http.StatusInternalServerError)
 			return
@@ -452,10 +685,14 @@ func statusHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-// Gestionnaire pour la liste des paires
+// Gestionnaire pour la liste des paires (d'un seul exchange avec ?exchange=)
 func pairsHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		rows, err := db.Query("SELECT DISTINCT pair FROM crypto_data")
+		exchange := r.URL.Query().Get("exchange")
+		rows, err := db.QueryContext(r.Context(),
+			"SELECT DISTINCT pair FROM crypto_latest WHERE ? = '' OR exchange = ? ORDER BY pair",
+			exchange, exchange,
+		)
 		if err != nil {
 			http.Error(w, "Erreur lors de la récupération des paires", http.StatusInternalServerError)
 			return
@@ -477,16 +714,52 @@ func pairsHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-// Gestionnaire pour les données d'une paire
-func pairDataHandler(db *sql.DB) http.HandlerFunc {
+// Gestionnaire pour les métadonnées d'une paire (décimales, minimums d'ordre, frais, statut)
+func pairInfoHandler(db *sql.DB, symbols *SymbolCatalog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pair := r.URL.Path[len("/api/pairs/"):]
+		if pair == "" {
+			http.Error(w, "Paire non spécifiée", http.StatusBadRequest)
+			return
+		}
+
+		info, err := GetPairInfo(r.Context(), db, symbols.PairName(r.Context(), pair))
+		if err == sql.ErrNoRows {
+			http.Error(w, "Paire non trouvée", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erreur lors de la récupération de la paire", http.StatusInternalServerError)
+			return
+		}
+		if ps, ok := symbols.Resolve(r.Context(), info.AltName); ok {
+			info.Symbol, info.BaseSymbol, info.QuoteSymbol = ps.Symbol, ps.Base, ps.Quote
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	}
+}
+
+// Gestionnaire pour les données d'une paire. ?columns= limite les champs retournés,
+// ?exchange= les relevés à un seul exchange.
+func pairDataHandler(db *sql.DB, symbols *SymbolCatalog) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		columns, err := parseTickColumns(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		exchange := r.URL.Query().Get("exchange")
+
 		// Extraire le nom de la paire de l'URL
 		path := r.URL.Path
 		// Normaliser le chemin pour gérer le cas avec ou sans slash à la fin
 		if path == "/api/data" || path == "/api/data/" {
 			// Si aucune paire spécifique n'est demandée, retourner toutes les paires
-			rows, err := db.Query(
-				"SELECT pair, ask_price, bid_price, last_trade_price, volume, high_price, low_price, timestamp FROM crypto_data",
+			rows, err := db.QueryContext(r.Context(),
+				"SELECT "+tickSelectColumns()+" FROM crypto_latest WHERE ? = '' OR exchange = ?",
+				exchange, exchange,
 			)
 			if err != nil {
 				http.Error(w, "Erreur lors de la récupération des données", http.StatusInternalServerError)
@@ -494,27 +767,20 @@ func pairDataHandler(db *sql.DB) http.HandlerFunc {
 			}
 			defer rows.Close()
 
-			var allData []map[string]interface{}
+			var ticks []Tick
 			for rows.Next() {
-				var pair string
-				var ask, bid, lastTrade, volume, high, low float64
-				var timestamp string
-				if err := rows.Scan(&pair, &ask, &bid, &lastTrade, &volume, &high, &low, &timestamp); err != nil {
+				var tick Tick
+				if err := scanTick(rows, &tick); err != nil {
 					http.Error(w, "Erreur lors de la lecture des données", http.StatusInternalServerError)
 					return
 				}
+				ticks = append(ticks, tick)
+			}
 
-				entry := map[string]interface{}{
-					"pair":      pair,
-					"ask":       ask,
-					"bid":       bid,
-					"last":      lastTrade,
-					"volume":    volume,
-					"high":      high,
-					"low":       low,
-					"timestamp": timestamp,
-				}
-				allData = append(allData, entry)
+			// Symboles résolus une fois la lecture terminée (le catalogue interroge aussi la base)
+			var allData []map[string]interface{}
+			for i := range ticks {
+				allData = append(allData, tickJSON(&ticks[i], columns, symbols.Symbol(r.Context(), ticks[i].Pair)))
 			}
 
 			if len(allData) == 0 {
@@ -527,16 +793,44 @@ func pairDataHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Extraire le nom de la paire
+		// Extraire le nom de la paire, sous n'importe quelle forme (XBTUSD, BTC/USD, BTC-USD...)
 		pair := path[len("/api/data/"):]
 		if pair == "" {
 			http.Error(w, "Paire non spécifiée", http.StatusBadRequest)
 			return
 		}
+		pair = symbols.PairName(r.Context(), pair)
+		symbol := symbols.Symbol(r.Context(), pair)
 
-		rows, err := db.Query(
-			"SELECT pair, ask_price, bid_price, last_trade_price, volume, high_price, low_price, timestamp FROM crypto_data WHERE pair = ?",
-			pair,
+		// Avec des paramètres de plage ou de pagination, retourner l'historique paginé
+		if hasHistoryParams(r.URL.Query()) {
+			query, err := parseHistoryQuery(pair, r.URL.Query())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			page, err := QueryHistory(r.Context(), db, query)
+			if err != nil {
+				http.Error(w, "Erreur lors de la récupération de l'historique", http.StatusInternalServerError)
+				return
+			}
+
+			response := struct {
+				Data       []map[string]interface{} `json:"data"`
+				NextCursor string                   `json:"next_cursor,omitempty"`
+			}{Data: []map[string]interface{}{}, NextCursor: page.NextCursor}
+			for i := range page.Data {
+				response.Data = append(response.Data, tickJSON(&page.Data[i], columns, symbol))
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+
+		rows, err := db.QueryContext(r.Context(),
+			"SELECT "+tickSelectColumns()+" FROM crypto_latest WHERE pair = ? AND (? = '' OR exchange = ?)",
+			pair, exchange, exchange,
 		)
 		if err != nil {
 			http.Error(w, "Erreur lors de la récupération des données", http.StatusInternalServerError)
@@ -546,25 +840,12 @@ func pairDataHandler(db *sql.DB) http.HandlerFunc {
 
 		var data []map[string]interface{}
 		for rows.Next() {
-			var pair string
-			var ask, bid, lastTrade, volume, high, low float64
-			var timestamp string
-			if err := rows.Scan(&pair, &ask, &bid, &lastTrade, &volume, &high, &low, &timestamp); err != nil {
+			var tick Tick
+			if err := scanTick(rows, &tick); err != nil {
 				http.Error(w, "Erreur lors de la lecture des données", http.StatusInternalServerError)
 				return
 			}
-
-			entry := map[string]interface{}{
-				"pair":      pair,
-				"ask":       ask,
-				"bid":       bid,
-				"last":      lastTrade,
-				"volume":    volume,
-				"high":      high,
-				"low":       low,
-				"timestamp": timestamp,
-			}
-			data = append(data, entry)
+			data = append(data, tickJSON(&tick, columns, symbol))
 		}
 
 		if len(data) == 0 {
@@ -577,16 +858,197 @@ func pairDataHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+// Gestionnaire pour les bougies OHLC d'une paire
+func candlesHandler(db *sql.DB, symbols *SymbolCatalog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pair := r.URL.Path[len("/api/candles/"):]
+		if pair == "" {
+			http.Error(w, "Paire non spécifiée", http.StatusBadRequest)
+			return
+		}
+		pair = symbols.PairName(r.Context(), pair)
+
+		intervalParam := r.URL.Query().Get("interval")
+		if intervalParam == "" {
+			intervalParam = "1h"
+		}
+		interval, err := parseCandleInterval(intervalParam)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		from, to, err := parseTimeRangeParams(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Bougies calculées à partir des relevés d'un exchange (kraken par défaut), ou avec
+		// source=<exchange> : bougies de l'exchange rapatriées par la commande backfill
+		var candles []Candle
+		switch source := r.URL.Query().Get("source"); source {
+		case "", "ticks":
+			exchange := r.URL.Query().Get("exchange")
+			if exchange == "" {
+				exchange = defaultExchange
+			}
+			candles, err = GetCandles(r.Context(), db, exchange, pair, interval, from, to)
+		case krakenExchangeName, binanceExchangeName, coinbaseExchangeName:
+			candles, err = GetStoredCandles(r.Context(), db, source, pair, int(interval.Minutes()), from, to)
+		default:
+			http.Error(w, fmt.Sprintf("paramètre source invalide: %q (attendu: ticks, kraken, binance ou coinbase)", source), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erreur lors du calcul des bougies", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(candles)
+	}
+}
+
+// Gestionnaire pour la comparaison des prix d'une paire entre exchanges : dernier
+// bid/ask archivé de chaque exchange (interrogé en direct avec ?live=true), meilleurs
+// prix, écart entre exchanges et historique de cet écart (?interval=, ?from=, ?to=)
+func compareHandler(db *sql.DB, symbols *SymbolCatalog, exchanges []Exchange) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pair := r.URL.Path[len("/api/compare/"):]
+		if pair == "" {
+			http.Error(w, "Paire non spécifiée", http.StatusBadRequest)
+			return
+		}
+		pair = symbols.PairName(r.Context(), pair)
+
+		intervalParam := r.URL.Query().Get("interval")
+		if intervalParam == "" {
+			intervalParam = defaultSpreadInterval
+		}
+		interval, err := parseCandleInterval(intervalParam)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		from, to, err := parseTimeRangeParams(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if from.IsZero() && to.IsZero() {
+			from = time.Now().Add(-defaultSpreadHistory)
+		}
+		// Âge maximal des prix archivés comparés ; 0 désactive le filtre
+		maxAge := defaultQuoteMaxAge
+		if value := r.URL.Query().Get("max_age"); value != "" {
+			if maxAge, err = time.ParseDuration(value); err != nil || maxAge < 0 {
+				http.Error(w, fmt.Sprintf("paramètre max_age invalide: %q (attendu: durée, ex. 5m ou 1h)", value), http.StatusBadRequest)
+				return
+			}
+		}
+		var since time.Time
+		if maxAge > 0 {
+			since = time.Now().Add(-maxAge)
+		}
+
+		comparison := Comparison{Pair: pair, Symbol: symbols.Symbol(r.Context(), pair)}
+		if live, _ := strconv.ParseBool(r.URL.Query().Get("live")); live {
+			comparison.Live = true
+			comparison.Venues = FetchQuotes(r.Context(), exchanges, pair)
+		} else if comparison.Venues, err = LatestQuotes(r.Context(), db, pair, since); err != nil {
+			http.Error(w, "Erreur lors de la récupération des prix", http.StatusInternalServerError)
+			return
+		}
+		if len(comparison.Venues) == 0 {
+			http.Error(w, "Aucun prix disponible pour cette paire", http.StatusNotFound)
+			return
+		}
+		comparison.Best = crossSpread(comparison.Venues)
+
+		if comparison.History, err = SpreadHistory(r.Context(), db, pair, interval, from, to); err != nil {
+			http.Error(w, "Erreur lors du calcul de l'historique des écarts", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(comparison)
+	}
+}
+
+// Gestionnaire pour le carnet d'ordres d'une paire à une date donnée
+func bookHandler(db *sql.DB, symbols *SymbolCatalog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pair := r.URL.Path[len("/api/book/"):]
+		if pair == "" {
+			http.Error(w, "Paire non spécifiée", http.StatusBadRequest)
+			return
+		}
+		pair = symbols.PairName(r.Context(), pair)
+
+		// Sans paramètre at, retourner l'instantané le plus récent
+		at := time.Now()
+		if value := r.URL.Query().Get("at"); value != "" {
+			var err error
+			if at, err = parseTimeParam(value); err != nil {
+				http.Error(w, fmt.Sprintf("paramètre at invalide: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		snapshot, err := GetNearestBookSnapshot(r.Context(), db, pair, at)
+		if err != nil {
+			http.Error(w, "Erreur lors de la récupération du carnet", http.StatusInternalServerError)
+			return
+		}
+		if snapshot == nil {
+			http.Error(w, "Aucun carnet disponible pour cette paire", http.StatusNotFound)
+			return
+		}
+		snapshot.Symbol = symbols.Symbol(r.Context(), pair)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(snapshot)
+	}
+}
+
 // Gestionnaire pour télécharger un fichier CSV
-func exportCSVHandler(db *sql.DB) http.HandlerFunc {
+func exportCSVHandler(db *sql.DB, symbols *SymbolCatalog) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		pair := r.URL.Path[len("/api/export/"):]
 		if pair == "" {
 			http.Error(w, "Paire non spécifiée", http.StatusBadRequest)
 			return
 		}
-
-		filename, err := ExportPairToCSV(db, pair)
+		pair = symbols.PairName(r.Context(), pair)
+		exchange := r.URL.Query().Get("exchange")
+
+		// Avec ?interval=, exporter les bougies OHLC plutôt que les relevés bruts
+		var filename string
+		var err error
+		if interval := r.URL.Query().Get("interval"); interval != "" {
+			if _, err := parseCandleInterval(interval); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var from, to time.Time
+			from, to, err = parseTimeRangeParams(r.URL.Query())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if exchange == "" {
+				exchange = defaultExchange
+			}
+			filename, err = ExportCandlesToCSV(r.Context(), db, exchange, pair, interval, from, to)
+		} else {
+			var columns []tickColumn
+			columns, err = parseTickColumns(r.URL.Query())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			filename, err = ExportPairToCSV(r.Context(), db, exchange, pair, columns)
+		}
 		if err != nil {
 			http.Error(w, "Erreur lors de l'export CSV", http.StatusInternalServerError)
 			return
@@ -614,7 +1076,7 @@ func exportLatestCSVHandler(db *sql.DB) http.HandlerFunc {
 
 		if len(files) == 0 {
 			// Si pas de fichier, en générer un nouveau
-			filename, err := ExportAllPairsToSingleCSV(db)
+			filename, err := ExportAllPairsToSingleCSV(r.Context(), db)
 			if err != nil {
 				http.Error(w, "Erreur lors de l'export CSV", http.StatusInternalServerError)
 				return
@@ -657,14 +1119,89 @@ func exportLatestCSVHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-// Configurer le serveur HTTP
-func setupHTTPServer(db *sql.DB) *http.Server {
+// Gestionnaire pour les métriques internes (état du limiteur de débit Kraken)
+func metricsHandler(client *KrakenClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		metrics := struct {
+			RateLimiter []RateLimiterStats `json:"rate_limiter"`
+		}{}
+		if client.Limiter != nil {
+			metrics.RateLimiter = client.Limiter.Stats()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(metrics)
+	}
+}
+
+// Gestionnaire pour le classement des paires par volume sur 24h converti dans une devise
+// de référence (?currency=USD par défaut), filtrable par devise de cotation (?quotes=USD,EUR)
+func rankingsHandler(client *KrakenClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		currency := query.Get("currency")
+		if currency == "" {
+			currency = client.Universe.Currency
+		}
+		limit := 0
+		if value := query.Get("limit"); value != "" {
+			var err error
+			if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+				http.Error(w, fmt.Sprintf("paramètre limit invalide: %q", value), http.StatusBadRequest)
+				return
+			}
+		}
+
+		ranking, err := client.GetRanking(r.Context(), currency)
+		if err != nil {
+			log.Println("Erreur lors du classement des paires:", err)
+			http.Error(w, "Erreur lors du classement des paires", http.StatusInternalServerError)
+			return
+		}
+		if !ranking.knownAsset(ranking.Currency) {
+			http.Error(w, fmt.Sprintf("Devise inconnue: %q", currency), http.StatusBadRequest)
+			return
+		}
+
+		if quotes := splitList(query.Get("quotes")); len(quotes) > 0 {
+			accepted := make(map[string]bool)
+			for _, quote := range quotes {
+				accepted[normalizeSymbol(quote)] = true
+			}
+			var pairs []PairRanking
+			for _, pair := range ranking.Pairs {
+				if accepted[pair.Quote] {
+					pairs = append(pairs, pair)
+				}
+			}
+			ranking.Pairs = pairs
+		}
+		if limit > 0 && len(ranking.Pairs) > limit {
+			ranking.Pairs = ranking.Pairs[:limit]
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ranking)
+	}
+}
+
+// Configurer le serveur HTTP. exchanges sont les exchanges archivés, interrogés
+// en direct par la comparaison des prix.
+func setupHTTPServer(client *KrakenClient, db *sql.DB, exchanges []Exchange) *http.Server {
+	symbols := NewSymbolCatalog(db)
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", indexHandler)
-	mux.HandleFunc("/api/status", statusHandler(db))
+	mux.HandleFunc("/api/status", statusHandler(client, db))
+	mux.HandleFunc("/api/metrics", metricsHandler(client))
 	mux.HandleFunc("/api/pairs", pairsHandler(db))
-	mux.HandleFunc("/api/data/", pairDataHandler(db))
-	mux.HandleFunc("/api/export/", exportCSVHandler(db))
+	mux.HandleFunc("/api/rankings", rankingsHandler(client))
+	mux.HandleFunc("/api/pairs/", pairInfoHandler(db, symbols))
+	mux.HandleFunc("/api/data/", pairDataHandler(db, symbols))
+	mux.HandleFunc("/api/candles/", candlesHandler(db, symbols))
+	mux.HandleFunc("/api/book/", bookHandler(db, symbols))
+	mux.HandleFunc("/api/compare/", compareHandler(db, symbols, exchanges))
+	mux.HandleFunc("/api/export/", exportCSVHandler(db, symbols))
 	mux.HandleFunc("/api/export-latest", exportLatestCSVHandler(db))
 
 	// Servir les fichiers CSV statiques
@@ -680,79 +1217,131 @@ func setupHTTPServer(db *sql.DB) *http.Server {
 
 // ------------------- Archivage des données -------------------
 
-// ArchiveData récupère les données de toutes les Asset Pairs et les stocke dans la BDD.
-func ArchiveData(db *sql.DB) {
-	pairs, err := GetAssetPairs()
+// ArchiveData récupère le ticker des paires suivies sur un exchange et les stocke dans la BDD.
+// Les appels sont réessayés en cas d'erreur temporaire jusqu'à l'échéance de ctx ;
+// l'annulation de ctx interrompt le cycle en cours.
+func ArchiveData(ctx context.Context, ex Exchange, db *sql.DB) {
+	ArchiveDataAt(ctx, ex, db, time.Now())
+}
+
+// ArchiveDataAt effectue un cycle d'archivage dont les relevés sont datés de at.
+// Utilisé par le rejeu des réponses enregistrées pour conserver leur date d'origine.
+func ArchiveDataAt(ctx context.Context, ex Exchange, db *sql.DB, at time.Time) {
+	pairs, err := ex.ListPairs(ctx)
 	if err != nil {
-		log.Println("Erreur récupération des paires:", err)
+		log.Printf("Erreur récupération des paires (%s): %v", ex.Name(), err)
 		return
 	}
-	log.Printf("Nombre de paires récupérées: %d\n", len(pairs))
+	log.Printf("Nombre de paires récupérées (%s): %d\n", ex.Name(), len(pairs))
+	if err := RecordPairUniverse(ctx, db, ex.Name(), pairs); err != nil {
+		log.Println("Erreur lors de l'enregistrement de l'univers des paires:", err)
+	}
+
+	ticks, err := ex.GetTickers(ctx, pairs)
+	if err != nil {
+		log.Printf("Erreur récupération des tickers (%s): %v", ex.Name(), err)
+	}
 
+	timestamp := at.UTC().Format(time.RFC3339)
 	for _, pair := range pairs {
-		// Utiliser le nom interne pour la requête Ticker
-		tickerInfo, err := GetTicker(pair.InternalName)
-		if err != nil {
-			log.Println("Erreur récupération ticker pour", pair.InternalName, ":", err)
+		if ctx.Err() != nil {
+			log.Println("Cycle d'archivage interrompu:", ctx.Err())
+			return
+		}
+		tick, ok := ticks[pair.AltName]
+		if !ok {
+			log.Printf("Ticker manquant pour %s (%s)", pair.InternalName, ex.Name())
 			continue
 		}
 
-		ask, _ := strconv.ParseFloat(tickerInfo.Ask[0], 64)
-		bid, _ := strconv.ParseFloat(tickerInfo.Bid[0], 64)
-		lastTrade, _ := strconv.ParseFloat(tickerInfo.Last[0], 64)
-		volume, _ := strconv.ParseFloat(tickerInfo.Volume[1], 64)
-		high, _ := strconv.ParseFloat(tickerInfo.High[0], 64)
-		low, _ := strconv.ParseFloat(tickerInfo.Low[0], 64)
-		timestamp := time.Now().Format(time.RFC3339)
-
 		// Stocker avec le nom alternatif pour l'affichage
-		InsertCryptoData(db, pair.AltName, ask, bid, lastTrade, volume, high, low, timestamp)
-		log.Printf("Archivé : %s | Ask: %.8f | Bid: %.8f | Last: %.8f | High: %.8f | Low: %.8f\n",
-			pair.AltName, ask, bid, lastTrade, high, low) // Augmenté de 4 à 8 décimales et ajouté High/Low
+		tick.Exchange = ex.Name()
+		tick.Timestamp = timestamp
+		InsertCryptoData(ctx, db, tick)
+		log.Printf("Archivé : %s (%s) | Ask: %s | Bid: %s | Last: %s | High: %s | Low: %s\n",
+			pair.AltName, ex.Name(), tick.Ask, tick.Bid, tick.Last, tick.High, tick.Low)
 	}
 }
 
-// ArchiveDataContinuously lance l'archivage des données à intervalles réguliers
-func ArchiveDataContinuously(db *sql.DB, interval time.Duration, stopChan <-chan struct{}, wg *sync.WaitGroup) {
+// StoreTicker ajoute un relevé du ticker Kraken d'une paire à l'historique.
+// Utilisé par l'ingestion WebSocket.
+func StoreTicker(ctx context.Context, db *sql.DB, pair string, tickerInfo *TickerInfo) {
+	tick := tickerInfo.Tick(pair, time.Now().UTC().Format(time.RFC3339))
+	InsertCryptoData(ctx, db, tick)
+	log.Printf("Archivé : %s | Ask: %s | Bid: %s | Last: %s | High: %s | Low: %s\n",
+		pair, tick.Ask, tick.Bid, tick.Last, tick.High, tick.Low)
+}
+
+// ArchiveDataContinuously lance l'archivage des données des exchanges à intervalles
+// réguliers jusqu'à l'annulation de ctx. Les exchanges sont interrogés en parallèle.
+func ArchiveDataContinuously(ctx context.Context, exchanges []Exchange, db *sql.DB, interval time.Duration, wg *sync.WaitGroup) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 	defer wg.Done()
 
-	counter := 0 // Compteur pour l'export CSV
-
 	for {
 		select {
 		case <-ticker.C:
 			log.Println("Démarrage d'un cycle d'archivage...")
-			ArchiveData(db)
-
-			counter++
-			log.Printf("Cycle d'archivage %d/5 terminé. Prochain export CSV dans %d minutes.", counter, 5-counter)
-
-			// Export CSV toutes les 5 minutes (après 5 cycles d'archivage d'une minute)
-			if counter >= 5 {
-				log.Println("Export du fichier CSV global...")
-				filename, err := ExportAllPairsToSingleCSV(db)
-				if err != nil {
-					log.Printf("Erreur lors de l'export CSV: %v", err)
-				} else {
-					log.Printf("Fichier CSV exporté: %s", filename)
-					counter = 0 // Réinitialiser le compteur
-				}
+			// Un cycle ne doit pas déborder sur le suivant, réessais compris
+			cycleCtx, cancel := context.WithTimeout(ctx, interval)
+			var cycle sync.WaitGroup
+			for _, ex := range exchanges {
+				cycle.Add(1)
+				go func(ex Exchange) {
+					defer cycle.Done()
+					ArchiveData(cycleCtx, ex, db)
+				}(ex)
+			}
+			cycle.Wait()
+			cancel()
+			if ctx.Err() != nil {
+				log.Println("Archivage arrêté")
+				return
 			}
+			log.Println("Cycle d'archivage terminé")
 
-		case <-stopChan:
+		case <-ctx.Done():
 			log.Println("Archivage arrêté")
 			return
 		}
 	}
 }
 
+// exportGlobalCSV exporte le fichier CSV global et indique si l'export a réussi
+func exportGlobalCSV(ctx context.Context, db *sql.DB) bool {
+	log.Println("Export du fichier CSV global...")
+	filename, err := ExportAllPairsToSingleCSV(ctx, db)
+	if err != nil {
+		log.Printf("Erreur lors de l'export CSV: %v", err)
+		return false
+	}
+	log.Printf("Fichier CSV exporté: %s", filename)
+	return true
+}
+
+// ExportCSVContinuously exporte le fichier CSV global à intervalles réguliers,
+// indépendamment des cycles d'archivage et de l'ingestion WebSocket.
+func ExportCSVContinuously(ctx context.Context, db *sql.DB, interval time.Duration, wg *sync.WaitGroup) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer wg.Done()
+
+	for {
+		select {
+		case <-ticker.C:
+			exportGlobalCSV(ctx, db)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 // ------------------- Affichage en Terminal -------------------
 
 // DisplayArchivedData affiche le contenu de la base SQLite dans le terminal.
 func DisplayArchivedData(db *sql.DB) {
-	rows, err := db.Query("SELECT pair, ask_price, bid_price, last_trade_price, volume, high_price, low_price, timestamp FROM crypto_data")
+	rows, err := db.Query("SELECT exchange, pair, ask_price, bid_price, last_trade_price, volume, high_price, low_price, timestamp FROM crypto_latest")
 	if err != nil {
 		log.Println("Erreur lors de la lecture de la BDD:", err)
 		return
@@ -761,36 +1350,116 @@ func DisplayArchivedData(db *sql.DB) {
 
 	fmt.Println("----- Données archivées -----")
 	for rows.Next() {
-		var pair string
-		var ask, bid, lastTrade, volume, high, low float64
+		var exchange, pair string
+		var ask, bid, lastTrade, volume, high, low Decimal
 		var timestamp string
-		if err := rows.Scan(&pair, &ask, &bid, &lastTrade, &volume, &high, &low, &timestamp); err != nil {
+		if err := rows.Scan(&exchange, &pair, &ask, &bid, &lastTrade, &volume, &high, &low, &timestamp); err != nil {
 			log.Println("Erreur de lecture:", err)
 			continue
 		}
-		fmt.Printf("Paire: %s | Ask: %.8f | Bid: %.8f | Last: %.8f | Vol: %.2f | High: %.8f | Low: %.8f | Timestamp: %s\n", 
-			pair, ask, bid, lastTrade, volume, high, low, timestamp) // Augmenté à 8 décimales pour les prix
+		fmt.Printf("Exchange: %s | Paire: %s | Ask: %s | Bid: %s | Last: %s | Vol: %s | High: %s | Low: %s | Timestamp: %s\n",
+			exchange, pair, ask, bid, lastTrade, volume, high, low, timestamp)
 	}
 	fmt.Println("-------------------------------")
 }
 
 // ------------------- Fonction principale -------------------
 
-func main() {
-	// Créer le dossier "data" s'il n'existe pas pour la base SQLite
+// Chemin de la base SQLite
+const dbPath = "data/crypto.db"
+
+// Délai laissé aux requêtes HTTP en cours lors de l'arrêt
+const httpShutdownTimeout = 10 * time.Second
+
+// ensureDataDir crée le dossier "data" s'il n'existe pas
+func ensureDataDir() {
 	if _, err := os.Stat("data"); os.IsNotExist(err) {
 		os.Mkdir("data", 0755)
 	}
+}
 
-	// Initialiser la base SQLite dans "data/crypto.db"
-	db := InitDB("data/crypto.db")
-	defer db.Close()
+// openDatabase ouvre la base SQLite en appliquant les migrations en attente
+func openDatabase() *sql.DB {
+	ensureDataDir()
+	return InitDB(dbPath)
+}
+
+func main() {
+	// Sous-commandes (reset, ...) : exécutées puis le programme se termine.
+	// Sans commande, ou avec seulement des options, lancer l'archivage et le serveur HTTP.
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := runServe(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	// Nettoyer la base pour repartir sur des données réelles
-	CleanDB(db)
+// runServe lance l'archivage continu et le serveur HTTP jusqu'à l'arrêt (Ctrl+C)
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	trades := fs.Bool("trades", false, "Archiver aussi les trades individuels (endpoint Trades)")
+	tradesInterval := fs.Duration("trades-interval", 1*time.Minute, "Intervalle entre deux collectes de trades")
+	tradesPairs := fs.String("trades-pairs", "", "Paires dont archiver les trades, séparées par des virgules (par défaut les paires archivées)")
+	depth := fs.Bool("depth", false, "Archiver aussi des instantanés du carnet d'ordres (endpoint Depth)")
+	depthInterval := fs.Duration("depth-interval", 1*time.Minute, "Intervalle entre deux instantanés du carnet")
+	depthLevels := fs.Int("depth-levels", defaultBookDepth, "Nombre de niveaux du carnet de chaque côté")
+	depthPairs := fs.String("depth-pairs", "", "Paires dont archiver le carnet, séparées par des virgules (par défaut les paires archivées)")
+	ws := fs.Bool("ws", false, "Ingestion temps réel par WebSocket au lieu de l'interrogation du ticker chaque minute")
+	wsURL := fs.String("ws-url", krakenWSURL, "URL du serveur WebSocket Kraken")
+	wsPairs := fs.String("ws-pairs", "", "Paires à suivre en WebSocket, séparées par des virgules (par défaut les paires archivées)")
+	wsChannels := fs.String("ws-channels", "ticker", "Canaux WebSocket, séparés par des virgules (ticker, trade, book)")
+	wsFlushInterval := fs.Duration("ws-flush-interval", 10*time.Second, "Intervalle d'écriture des tickers et carnets reçus en WebSocket")
+	newKrakenClient := addKrakenFlags(fs)
+	newSelection := addUniverseFlags(fs)
+	newExchanges := addExchangeFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := newKrakenClient()
+	if err != nil {
+		return err
+	}
+	client.Universe = newSelection()
+	exchanges, err := newExchanges(client)
+	if err != nil {
+		return err
+	}
+
+	// Contexte annulé à l'arrêt (Ctrl+C ou SIGTERM) : interrompt les appels Kraken,
+	// écritures et exports en cours
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// En mode WebSocket, les paires sont résolues une fois au démarrage
+	var wsIngestor *WSIngestor
+	if *ws {
+		var pairs []PairMapping
+		var err error
+		if *wsPairs != "" {
+			pairs, err = client.ResolveAssetPairs(ctx, strings.Split(*wsPairs, ","))
+		} else {
+			pairs, err = client.GetAssetPairs(ctx)
+		}
+		if err != nil {
+			return fmt.Errorf("erreur récupération des paires pour le WebSocket: %v", err)
+		}
+		wsIngestor = NewWSIngestor(nil, pairs, strings.Split(*wsChannels, ","))
+		wsIngestor.URL = *wsURL
+		wsIngestor.BookDepth = *depthLevels
+		wsIngestor.FlushInterval = *wsFlushInterval
+	}
+
+	// Initialiser la base SQLite dans "data/crypto.db".
+	// L'historique est conservé entre deux démarrages : utiliser la commande "reset" pour le vider.
+	db := openDatabase()
+	defer db.Close()
 
 	// Vérifier le statut du serveur Kraken
-	serverTime, err := GetServerStatus()
+	serverTime, err := client.GetServerStatus(ctx)
 	if err != nil {
 		log.Printf("Erreur lors de la récupération du statut du serveur: %v", err)
 	} else {
@@ -800,9 +1469,19 @@ func main() {
 		log.Printf("- Décalage avec serveur: %d secondes\n",
 			time.Now().Unix()-serverTime.Unixtime)
 	}
+	for _, ex := range exchanges {
+		if ex.Name() == krakenExchangeName {
+			continue
+		}
+		if exTime, err := ex.ServerTime(ctx); err != nil {
+			log.Printf("Erreur lors de la récupération de l'heure du serveur %s: %v", ex.Name(), err)
+		} else {
+			log.Printf("Décalage avec le serveur %s: %d secondes\n", ex.Name(), time.Now().Unix()-exTime.Unix())
+		}
+	}
 
 	// Mettre en place le serveur HTTP
-	server := setupHTTPServer(db)
+	server := setupHTTPServer(client, db, exchanges)
 
 	// Lancer le serveur HTTP dans une goroutine
 	go func() {
@@ -813,23 +1492,73 @@ func main() {
 	}()
 
 	// Configurer l'archivage des données
-	stopChan := make(chan struct{})
 	var wg sync.WaitGroup
 
-	// Archivage toutes les minutes pour mise à jour fréquente
-	// et export CSV toutes les 5 minutes
+	// Catalogue des actifs et des paires, rafraîchi chaque jour
+	wg.Add(1)
+	go RefreshCatalogContinuously(ctx, client, db, catalogRefreshInterval, &wg)
+
+	// Export CSV toutes les 5 minutes
 	wg.Add(1)
-	go ArchiveDataContinuously(db, 1*time.Minute, stopChan, &wg)
+	go ExportCSVContinuously(ctx, db, 5*time.Minute, &wg)
+
+	if wsIngestor != nil {
+		// Ingestion WebSocket en continu : Kraken n'est plus interrogé chaque minute
+		wsIngestor.DB = db
+		if err := RecordPairUniverse(ctx, db, krakenExchangeName, wsIngestor.Pairs); err != nil {
+			log.Println("Erreur lors de l'enregistrement de l'univers des paires:", err)
+		}
+		wg.Add(1)
+		go wsIngestor.Run(ctx, &wg)
+
+		var others []Exchange
+		for _, ex := range exchanges {
+			if ex.Name() != krakenExchangeName {
+				others = append(others, ex)
+			}
+		}
+		exchanges = others
+	}
+	if len(exchanges) > 0 {
+		// Archivage toutes les minutes pour mise à jour fréquente
+		wg.Add(1)
+		go ArchiveDataContinuously(ctx, exchanges, db, 1*time.Minute, &wg)
+	}
+
+	// Collecte optionnelle des trades individuels
+	if *trades {
+		var names []string
+		if *tradesPairs != "" {
+			names = strings.Split(*tradesPairs, ",")
+		}
+		wg.Add(1)
+		go CollectTradesContinuously(ctx, client, db, names, *tradesInterval, &wg)
+	}
+
+	// Collecte optionnelle des instantanés du carnet d'ordres
+	if *depth {
+		var names []string
+		if *depthPairs != "" {
+			names = strings.Split(*depthPairs, ",")
+		}
+		wg.Add(1)
+		go CollectDepthContinuously(ctx, client, db, names, *depthLevels, *depthInterval, &wg)
+	}
 
 	// Attendre l'arrêt (Ctrl+C)
 	fmt.Println("Serveur démarré. Appuyez sur Ctrl+C pour arrêter.")
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	<-c
+	<-ctx.Done()
+	stop()
 
-	// Arrêt propre
+	// Arrêt propre : les requêtes HTTP en cours ont quelques secondes pour se terminer,
+	// pendant que l'archivage interrompt son cycle
 	log.Println("Arrêt du serveur...")
-	close(stopChan)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Arrêt forcé du serveur HTTP: %v", err)
+	}
 	wg.Wait()
 	log.Println("Serveur arrêté")
+	return nil
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ------------------- Migrations du schéma SQLite -------------------
+
+// Les migrations sont embarquées dans le binaire et appliquées dans l'ordre de leur numéro.
+// Chaque fichier est nommé NNNN_description.sql et n'est jamais modifié une fois publié :
+// toute évolution du schéma passe par un nouveau fichier.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Structure décrivant une migration
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// loadMigrations lit les migrations embarquées, triées par version
+func loadMigrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("nom de migration invalide %s: %v", name, err)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("version de migration %d en double: %s et %s", version, other, name)
+		}
+		seen[version] = name
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    strings.TrimSuffix(name, ".sql"),
+			SQL:     string(content),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// ensureSchemaVersionTable crée la table de suivi des migrations si nécessaire
+func ensureSchemaVersionTable(db *sql.DB) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	);`)
+	return err
+}
+
+// SchemaVersion retourne la version courante du schéma (0 si aucune migration appliquée).
+// Elle ne modifie pas la base : sans table schema_version, la version est 0.
+func SchemaVersion(db *sql.DB) (int, error) {
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'").Scan(&tables); err != nil {
+		return 0, err
+	}
+	if tables == 0 {
+		return 0, nil
+	}
+	var version int
+	err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+// PendingMigrations retourne les migrations qui restent à appliquer
+func PendingMigrations(db *sql.DB) ([]Migration, error) {
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// MigrateDB applique les migrations en attente, chacune dans sa propre transaction
+func MigrateDB(db *sql.DB) error {
+	if err := ensureSchemaVersionTable(db); err != nil {
+		return err
+	}
+	pending, err := PendingMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pending {
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %s: %v", m.Name, err)
+		}
+		log.Printf("Migration appliquée: %s", m.Name)
+	}
+	return nil
+}
+
+// applyMigration exécute une migration et enregistre sa version de manière atomique
+func applyMigration(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)",
+		m.Version, m.Name, time.Now().UTC().Format(time.RFC3339),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,12 @@
+-- Schéma d'origine : une ligne par paire, mise à jour à chaque relevé
+CREATE TABLE IF NOT EXISTS crypto_data (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	pair TEXT UNIQUE,
+	ask_price REAL,
+	bid_price REAL,
+	last_trade_price REAL,
+	volume REAL,
+	high_price REAL,
+	low_price REAL,
+	timestamp DATETIME
+);
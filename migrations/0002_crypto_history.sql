@@ -0,0 +1,31 @@
+-- Historique en ajout seul : chaque relevé est conservé
+CREATE TABLE IF NOT EXISTS crypto_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	pair TEXT NOT NULL,
+	ask_price REAL,
+	bid_price REAL,
+	last_trade_price REAL,
+	volume REAL,
+	high_price REAL,
+	low_price REAL,
+	timestamp DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_crypto_history_pair_timestamp
+	ON crypto_history (pair, timestamp);
+
+-- Reprise des relevés de l'ancienne table
+INSERT INTO crypto_history (pair, ask_price, bid_price, last_trade_price, volume, high_price, low_price, timestamp)
+SELECT pair, ask_price, bid_price, last_trade_price, volume, high_price, low_price, timestamp
+FROM crypto_data
+WHERE pair IS NOT NULL AND timestamp IS NOT NULL;
+
+DROP TABLE crypto_data;
+
+-- Dernier relevé par paire, dérivé de l'historique.
+-- SQLite garantit que les colonnes non agrégées proviennent de la ligne du MAX().
+CREATE VIEW IF NOT EXISTS crypto_latest AS
+SELECT id, pair, ask_price, bid_price, last_trade_price, volume, high_price, low_price,
+       MAX(timestamp) AS timestamp
+FROM crypto_history
+GROUP BY pair;
@@ -0,0 +1,15 @@
+-- Bougies OHLC fournies par l'exchange (rattrapage de l'historique).
+-- interval_minutes suit la convention Kraken (1, 5, 15, 60, 1440...).
+CREATE TABLE IF NOT EXISTS crypto_candles (
+	pair TEXT NOT NULL,
+	interval_minutes INTEGER NOT NULL,
+	time DATETIME NOT NULL,
+	open REAL,
+	high REAL,
+	low REAL,
+	close REAL,
+	vwap REAL,
+	volume REAL,
+	trade_count INTEGER,
+	PRIMARY KEY (pair, interval_minutes, time)
+);
@@ -0,0 +1,26 @@
+-- Trades individuels (endpoint Trades de Kraken)
+CREATE TABLE IF NOT EXISTS crypto_trades (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	pair TEXT NOT NULL,
+	trade_id INTEGER,
+	price REAL NOT NULL,
+	volume REAL NOT NULL,
+	time DATETIME NOT NULL,
+	side TEXT NOT NULL,
+	order_type TEXT NOT NULL,
+	misc TEXT
+);
+
+-- Un trade n'est jamais enregistré deux fois (les trade_id NULL restent distincts)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_crypto_trades_pair_trade_id
+	ON crypto_trades (pair, trade_id);
+
+CREATE INDEX IF NOT EXISTS idx_crypto_trades_pair_time
+	ON crypto_trades (pair, time);
+
+-- Curseur "last" de Kraken par paire, pour reprendre la collecte sans trou après un redémarrage
+CREATE TABLE IF NOT EXISTS trade_cursors (
+	pair TEXT PRIMARY KEY,
+	last TEXT NOT NULL,
+	updated_at DATETIME NOT NULL
+);
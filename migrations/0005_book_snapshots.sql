@@ -0,0 +1,14 @@
+-- Instantanés du carnet d'ordres (endpoint Depth de Kraken).
+-- asks et bids sont des tableaux JSON de niveaux [prix, volume, timestamp],
+-- interrogeables avec les fonctions json_each() de SQLite.
+CREATE TABLE IF NOT EXISTS book_snapshots (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	pair TEXT NOT NULL,
+	time DATETIME NOT NULL,
+	depth INTEGER NOT NULL,
+	asks TEXT NOT NULL,
+	bids TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_book_snapshots_pair_time
+	ON book_snapshots (pair, time);
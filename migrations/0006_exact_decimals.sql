@@ -0,0 +1,82 @@
+-- Prix et volumes stockés en texte décimal exact (tels que renvoyés par Kraken)
+-- plutôt qu'en REAL. SQLite ne permet pas de changer le type d'une colonne :
+-- chaque table est recréée puis les données existantes recopiées.
+
+DROP VIEW IF EXISTS crypto_latest;
+
+CREATE TABLE crypto_history_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	pair TEXT NOT NULL,
+	ask_price TEXT,
+	bid_price TEXT,
+	last_trade_price TEXT,
+	volume TEXT,
+	high_price TEXT,
+	low_price TEXT,
+	timestamp DATETIME NOT NULL
+);
+
+-- Les identifiants sont conservés : ils servent de curseur de pagination
+INSERT INTO crypto_history_new (id, pair, ask_price, bid_price, last_trade_price, volume, high_price, low_price, timestamp)
+SELECT id, pair, CAST(ask_price AS TEXT), CAST(bid_price AS TEXT), CAST(last_trade_price AS TEXT),
+       CAST(volume AS TEXT), CAST(high_price AS TEXT), CAST(low_price AS TEXT), timestamp
+FROM crypto_history;
+
+DROP TABLE crypto_history;
+ALTER TABLE crypto_history_new RENAME TO crypto_history;
+
+CREATE INDEX IF NOT EXISTS idx_crypto_history_pair_timestamp
+	ON crypto_history (pair, timestamp);
+
+CREATE VIEW IF NOT EXISTS crypto_latest AS
+SELECT id, pair, ask_price, bid_price, last_trade_price, volume, high_price, low_price,
+       MAX(timestamp) AS timestamp
+FROM crypto_history
+GROUP BY pair;
+
+CREATE TABLE crypto_candles_new (
+	pair TEXT NOT NULL,
+	interval_minutes INTEGER NOT NULL,
+	time DATETIME NOT NULL,
+	open TEXT,
+	high TEXT,
+	low TEXT,
+	close TEXT,
+	vwap TEXT,
+	volume TEXT,
+	trade_count INTEGER,
+	PRIMARY KEY (pair, interval_minutes, time)
+);
+
+INSERT INTO crypto_candles_new (pair, interval_minutes, time, open, high, low, close, vwap, volume, trade_count)
+SELECT pair, interval_minutes, time, CAST(open AS TEXT), CAST(high AS TEXT), CAST(low AS TEXT),
+       CAST(close AS TEXT), CAST(vwap AS TEXT), CAST(volume AS TEXT), trade_count
+FROM crypto_candles;
+
+DROP TABLE crypto_candles;
+ALTER TABLE crypto_candles_new RENAME TO crypto_candles;
+
+CREATE TABLE crypto_trades_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	pair TEXT NOT NULL,
+	trade_id INTEGER,
+	price TEXT NOT NULL,
+	volume TEXT NOT NULL,
+	time DATETIME NOT NULL,
+	side TEXT NOT NULL,
+	order_type TEXT NOT NULL,
+	misc TEXT
+);
+
+INSERT INTO crypto_trades_new (id, pair, trade_id, price, volume, time, side, order_type, misc)
+SELECT id, pair, trade_id, CAST(price AS TEXT), CAST(volume AS TEXT), time, side, order_type, misc
+FROM crypto_trades;
+
+DROP TABLE crypto_trades;
+ALTER TABLE crypto_trades_new RENAME TO crypto_trades;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_crypto_trades_pair_trade_id
+	ON crypto_trades (pair, trade_id);
+
+CREATE INDEX IF NOT EXISTS idx_crypto_trades_pair_time
+	ON crypto_trades (pair, time);
@@ -0,0 +1,29 @@
+-- Catalogue des paires (endpoint AssetPairs de Kraken), rafraîchi chaque jour.
+-- Les listes (frais, effets de levier) sont stockées en JSON.
+CREATE TABLE IF NOT EXISTS pairs (
+	name TEXT PRIMARY KEY,
+	altname TEXT NOT NULL,
+	wsname TEXT,
+	aclass_base TEXT,
+	base TEXT NOT NULL,
+	aclass_quote TEXT,
+	quote TEXT NOT NULL,
+	pair_decimals INTEGER NOT NULL,
+	cost_decimals INTEGER,
+	lot_decimals INTEGER NOT NULL,
+	lot_multiplier INTEGER,
+	leverage_buy TEXT,
+	leverage_sell TEXT,
+	fees TEXT,
+	fees_maker TEXT,
+	fee_volume_currency TEXT,
+	margin_call INTEGER,
+	margin_stop INTEGER,
+	ordermin TEXT,
+	costmin TEXT,
+	tick_size TEXT,
+	status TEXT,
+	updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_pairs_altname ON pairs (altname);
@@ -0,0 +1,15 @@
+-- Catalogue des actifs (endpoint Assets de Kraken), rafraîchi avec celui des paires.
+-- symbol est le symbole usuel de l'actif (BTC pour XBT, DOGE pour XDG).
+CREATE TABLE IF NOT EXISTS assets (
+	name TEXT PRIMARY KEY,
+	aclass TEXT,
+	altname TEXT NOT NULL,
+	decimals INTEGER,
+	display_decimals INTEGER,
+	collateral_value TEXT,
+	status TEXT,
+	symbol TEXT NOT NULL,
+	updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_assets_symbol ON assets (symbol);
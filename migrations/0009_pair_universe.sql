@@ -0,0 +1,17 @@
+-- Univers des paires suivies par l'archivage, réévalué périodiquement selon la
+-- politique de sélection (liste blanche, liste noire, devises de cotation, classement).
+-- Chaque ajout ou retrait de paire est conservé dans pair_universe_changes.
+CREATE TABLE IF NOT EXISTS pair_universe (
+	pair TEXT PRIMARY KEY,
+	added_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS pair_universe_changes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	pair TEXT NOT NULL,
+	change TEXT NOT NULL, -- added, removed
+	timestamp DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_pair_universe_changes_timestamp
+	ON pair_universe_changes (timestamp);
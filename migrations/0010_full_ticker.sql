@@ -0,0 +1,28 @@
+-- Ticker Kraken complet dans l'historique : volumes de lot du meilleur ask/bid,
+-- volume du dernier trade, ouverture du jour, VWAP et nombre de trades, et
+-- distinction entre la journée en cours et les dernières 24h pour v/h/l.
+-- Les colonnes existantes gardent leur sens : volume sur 24h, high/low du jour.
+ALTER TABLE crypto_history ADD COLUMN ask_whole_lot_volume TEXT;
+ALTER TABLE crypto_history ADD COLUMN ask_lot_volume TEXT;
+ALTER TABLE crypto_history ADD COLUMN bid_whole_lot_volume TEXT;
+ALTER TABLE crypto_history ADD COLUMN bid_lot_volume TEXT;
+ALTER TABLE crypto_history ADD COLUMN last_trade_volume TEXT;
+ALTER TABLE crypto_history ADD COLUMN open_price TEXT;
+ALTER TABLE crypto_history ADD COLUMN volume_today TEXT;
+ALTER TABLE crypto_history ADD COLUMN high_24h TEXT;
+ALTER TABLE crypto_history ADD COLUMN low_24h TEXT;
+ALTER TABLE crypto_history ADD COLUMN vwap_today TEXT;
+ALTER TABLE crypto_history ADD COLUMN vwap_24h TEXT;
+ALTER TABLE crypto_history ADD COLUMN trades_today INTEGER;
+ALTER TABLE crypto_history ADD COLUMN trades_24h INTEGER;
+
+DROP VIEW IF EXISTS crypto_latest;
+
+CREATE VIEW IF NOT EXISTS crypto_latest AS
+SELECT id, pair, ask_price, bid_price, last_trade_price, volume, high_price, low_price,
+       ask_whole_lot_volume, ask_lot_volume, bid_whole_lot_volume, bid_lot_volume,
+       last_trade_volume, open_price, volume_today, high_24h, low_24h,
+       vwap_today, vwap_24h, trades_today, trades_24h,
+       MAX(timestamp) AS timestamp
+FROM crypto_history
+GROUP BY pair;
@@ -0,0 +1,57 @@
+-- Plusieurs exchanges archivés par la même instance : chaque relevé, bougie et
+-- paire suivie indique son exchange. Les données existantes viennent de Kraken.
+ALTER TABLE crypto_history ADD COLUMN exchange TEXT NOT NULL DEFAULT 'kraken';
+
+CREATE INDEX IF NOT EXISTS idx_crypto_history_exchange_pair_timestamp
+	ON crypto_history (exchange, pair, timestamp);
+
+-- Dernier relevé par exchange et par paire
+DROP VIEW IF EXISTS crypto_latest;
+
+CREATE VIEW IF NOT EXISTS crypto_latest AS
+SELECT id, exchange, pair, ask_price, bid_price, last_trade_price, volume, high_price, low_price,
+       ask_whole_lot_volume, ask_lot_volume, bid_whole_lot_volume, bid_lot_volume,
+       last_trade_volume, open_price, volume_today, high_24h, low_24h,
+       vwap_today, vwap_24h, trades_today, trades_24h,
+       MAX(timestamp) AS timestamp
+FROM crypto_history
+GROUP BY exchange, pair;
+
+-- La clé des bougies inclut l'exchange : la table est recréée
+CREATE TABLE crypto_candles_new (
+	exchange TEXT NOT NULL DEFAULT 'kraken',
+	pair TEXT NOT NULL,
+	interval_minutes INTEGER NOT NULL,
+	time DATETIME NOT NULL,
+	open TEXT,
+	high TEXT,
+	low TEXT,
+	close TEXT,
+	vwap TEXT,
+	volume TEXT,
+	trade_count INTEGER,
+	PRIMARY KEY (exchange, pair, interval_minutes, time)
+);
+
+INSERT INTO crypto_candles_new (pair, interval_minutes, time, open, high, low, close, vwap, volume, trade_count)
+SELECT pair, interval_minutes, time, open, high, low, close, vwap, volume, trade_count
+FROM crypto_candles;
+
+DROP TABLE crypto_candles;
+ALTER TABLE crypto_candles_new RENAME TO crypto_candles;
+
+-- Univers des paires suivies, par exchange
+CREATE TABLE pair_universe_new (
+	exchange TEXT NOT NULL DEFAULT 'kraken',
+	pair TEXT NOT NULL,
+	added_at DATETIME NOT NULL,
+	PRIMARY KEY (exchange, pair)
+);
+
+INSERT INTO pair_universe_new (pair, added_at)
+SELECT pair, added_at FROM pair_universe;
+
+DROP TABLE pair_universe;
+ALTER TABLE pair_universe_new RENAME TO pair_universe;
+
+ALTER TABLE pair_universe_changes ADD COLUMN exchange TEXT NOT NULL DEFAULT 'kraken';
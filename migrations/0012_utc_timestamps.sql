@@ -0,0 +1,8 @@
+-- Les premières versions enregistraient les relevés à l'heure locale avec son décalage
+-- (2025-03-14T10:00:00+01:00), les suivantes en UTC (2025-03-14T09:00:00Z). Les dates
+-- étant comparées comme du texte (MAX(timestamp) de crypto_latest, plages de l'historique),
+-- tous les relevés sont ramenés en UTC. SQLite applique le décalage lors de la conversion.
+UPDATE crypto_history
+SET timestamp = strftime('%Y-%m-%dT%H:%M:%SZ', timestamp)
+WHERE timestamp NOT LIKE '%Z'
+  AND strftime('%Y-%m-%dT%H:%M:%SZ', timestamp) IS NOT NULL;
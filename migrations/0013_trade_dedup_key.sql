@@ -0,0 +1,9 @@
+-- Les trades reçus par WebSocket n'ont pas d'identifiant : après une reconnexion,
+-- Kraken peut renvoyer un message déjà enregistré. Chaque trade WebSocket reçoit une clé
+-- formée du premier trade de son message et de sa position dans celui-ci (voir
+-- setDedupKeys). Les trades déjà enregistrés, sans clé (NULL), sont conservés tels quels :
+-- leurs messages d'origine ne peuvent être reconstitués.
+ALTER TABLE crypto_trades ADD COLUMN dedup_key TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_crypto_trades_pair_dedup_key
+	ON crypto_trades (pair, dedup_key);
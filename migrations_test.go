@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openDBBefore ouvre une base dont le schéma s'arrête avant la migration version
+func openDBBefore(t *testing.T, version int) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "crypto.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureSchemaVersionTable(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations {
+		if m.Version >= version {
+			break
+		}
+		if err := applyMigration(db, m); err != nil {
+			t.Fatalf("%s: %v", m.Name, err)
+		}
+	}
+	return db
+}
+
+func TestMigrationNormalizesLegacyTimestamps(t *testing.T) {
+	// Schéma antérieur à la normalisation, avec des relevés à l'heure locale et en UTC
+	db := openDBBefore(t, 12)
+	for _, ts := range []string{"2025-03-14T10:30:00+01:00", "2025-03-14T09:45:00Z", "2025-03-14T05:50:00-04:00"} {
+		if _, err := db.Exec("INSERT INTO crypto_history (exchange, pair, ask_price, timestamp) VALUES ('kraken', 'XBTUSD', ?, ?)", ts, ts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := MigrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT timestamp FROM crypto_history ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var ts string
+		if err := rows.Scan(&ts); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ts)
+	}
+	want := []string{"2025-03-14T09:30:00Z", "2025-03-14T09:45:00Z", "2025-03-14T09:50:00Z"}
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("timestamps = %v, attendu %v", got, want)
+		}
+	}
+
+	// Le dernier relevé est bien le plus récent en temps réel, pas en ordre alphabétique
+	var latest string
+	if err := db.QueryRow("SELECT timestamp FROM crypto_latest WHERE pair = 'XBTUSD'").Scan(&latest); err != nil || latest != "2025-03-14T09:50:00Z" {
+		t.Errorf("crypto_latest = %q, %v", latest, err)
+	}
+}
+
+func TestMigrationKeepsTradesWithoutID(t *testing.T) {
+	// Trades WebSocket (sans identifiant) identiques enregistrés avant la clé de dédoublonnage :
+	// rien ne permet de distinguer un doublon de deux exécutions identiques
+	db := openDBBefore(t, 13)
+	insert := "INSERT INTO crypto_trades (pair, trade_id, price, volume, time, side, order_type) VALUES ('XBTUSD', ?, ?, '0.5', '2025-01-01T12:00:00.123456Z', 'buy', 'market')"
+	for _, trade := range [][]interface{}{{nil, "50000.1"}, {nil, "50000.1"}, {nil, "50000.2"}, {7, "50000.1"}} {
+		if _, err := db.Exec(insert, trade...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := MigrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM crypto_trades").Scan(&count); err != nil || count != 4 {
+		t.Errorf("%d trade(s) après migration, attendu 4 (%v)", count, err)
+	}
+	// Un message WebSocket renvoyé n'est ajouté qu'une fois, ses deux exécutions identiques comprises
+	fill := Trade{Price: "50000.2", Volume: "0.5", Time: time.Date(2025, 1, 1, 12, 0, 0, 123456000, time.UTC), Side: "buy", OrderType: "market"}
+	for _, want := range []int{2, 0} {
+		trades := []Trade{fill, fill}
+		setDedupKeys(trades)
+		if inserted, err := InsertTrades(context.Background(), db, "XBTUSD", trades, ""); err != nil || inserted != want {
+			t.Errorf("InsertTrades = %d, %v, attendu %d", inserted, err, want)
+		}
+	}
+}
+
+func TestMigrateStatusIsReadOnly(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	// Sans base, status ne crée ni le dossier ni le fichier
+	if err := runMigrate([]string{"status"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat("data"); !os.IsNotExist(err) {
+		t.Errorf("status a créé le dossier data (%v)", err)
+	}
+
+	// Base existante sans table schema_version : version 0, table non créée
+	os.Mkdir("data", 0755)
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE crypto_data (pair TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := SchemaVersion(db); err != nil || version != 0 {
+		t.Errorf("SchemaVersion = %d, %v ; attendu 0", version, err)
+	}
+	if err := runMigrate([]string{"status"}); err != nil {
+		t.Fatal(err)
+	}
+	var tables int
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'schema_version'").Scan(&tables)
+	if tables != 0 {
+		t.Error("status a créé la table schema_version")
+	}
+}
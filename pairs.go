@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// ------------------- Catalogue des paires (AssetPairs) -------------------
+
+// Intervalle de rafraîchissement du catalogue des actifs et des paires
+const catalogRefreshInterval = 24 * time.Hour
+
+// Colonnes de la table pairs, dans l'ordre de scanAssetPair
+const pairColumns = `name, altname, wsname, aclass_base, base, aclass_quote, quote,
+	pair_decimals, cost_decimals, lot_decimals, lot_multiplier, leverage_buy, leverage_sell,
+	fees, fees_maker, fee_volume_currency, margin_call, margin_stop,
+	ordermin, costmin, tick_size, status, updated_at`
+
+// SavePairs enregistre ou met à jour les métadonnées des paires dans une même transaction
+func SavePairs(ctx context.Context, db *sql.DB, pairs map[string]AssetPair) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO pairs (`+pairColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			altname=excluded.altname,
+			wsname=excluded.wsname,
+			aclass_base=excluded.aclass_base,
+			base=excluded.base,
+			aclass_quote=excluded.aclass_quote,
+			quote=excluded.quote,
+			pair_decimals=excluded.pair_decimals,
+			cost_decimals=excluded.cost_decimals,
+			lot_decimals=excluded.lot_decimals,
+			lot_multiplier=excluded.lot_multiplier,
+			leverage_buy=excluded.leverage_buy,
+			leverage_sell=excluded.leverage_sell,
+			fees=excluded.fees,
+			fees_maker=excluded.fees_maker,
+			fee_volume_currency=excluded.fee_volume_currency,
+			margin_call=excluded.margin_call,
+			margin_stop=excluded.margin_stop,
+			ordermin=excluded.ordermin,
+			costmin=excluded.costmin,
+			tick_size=excluded.tick_size,
+			status=excluded.status,
+			updated_at=excluded.updated_at`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for name, p := range pairs {
+		leverageBuy, _ := json.Marshal(p.LeverageBuy)
+		leverageSell, _ := json.Marshal(p.LeverageSell)
+		fees, _ := json.Marshal(p.Fees)
+		feesMaker, _ := json.Marshal(p.FeesMaker)
+		if _, err := stmt.ExecContext(ctx, name, p.AltName, p.WSName, p.AClassBase, p.Base, p.AClassQuote, p.Quote,
+			p.PairDecimals, p.CostDecimals, p.LotDecimals, p.LotMultiplier, string(leverageBuy), string(leverageSell),
+			string(fees), string(feesMaker), p.FeeVolumeCurrency, p.MarginCall, p.MarginStop,
+			p.OrderMin, p.CostMin, p.TickSize, p.Status, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// scanAssetPair lit une paire depuis une ligne de la table pairs
+func scanAssetPair(row *sql.Row) (*AssetPair, error) {
+	var p AssetPair
+	var wsName, aclassBase, aclassQuote, feeVolumeCurrency, status sql.NullString
+	var leverageBuy, leverageSell, fees, feesMaker sql.NullString
+	var costDecimals, lotMultiplier, marginCall, marginStop sql.NullInt64
+	if err := row.Scan(&p.Name, &p.AltName, &wsName, &aclassBase, &p.Base, &aclassQuote, &p.Quote,
+		&p.PairDecimals, &costDecimals, &p.LotDecimals, &lotMultiplier, &leverageBuy, &leverageSell,
+		&fees, &feesMaker, &feeVolumeCurrency, &marginCall, &marginStop,
+		&p.OrderMin, &p.CostMin, &p.TickSize, &status, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	p.WSName = wsName.String
+	p.AClassBase = aclassBase.String
+	p.AClassQuote = aclassQuote.String
+	p.FeeVolumeCurrency = feeVolumeCurrency.String
+	p.Status = status.String
+	p.CostDecimals = int(costDecimals.Int64)
+	p.LotMultiplier = int(lotMultiplier.Int64)
+	p.MarginCall = int(marginCall.Int64)
+	p.MarginStop = int(marginStop.Int64)
+
+	// Listes stockées en JSON ; une valeur illisible laisse la liste vide
+	for _, field := range []struct {
+		data sql.NullString
+		dest interface{}
+	}{
+		{leverageBuy, &p.LeverageBuy},
+		{leverageSell, &p.LeverageSell},
+		{fees, &p.Fees},
+		{feesMaker, &p.FeesMaker},
+	} {
+		if field.data.Valid {
+			json.Unmarshal([]byte(field.data.String), field.dest)
+		}
+	}
+	return &p, nil
+}
+
+// GetPairInfo retourne les métadonnées d'une paire par son nom interne (XXBTZUSD),
+// alternatif (XBTUSD) ou WebSocket (XBT/USD), sans tenir compte de la casse.
+// Retourne sql.ErrNoRows si la paire est inconnue.
+func GetPairInfo(ctx context.Context, db *sql.DB, name string) (*AssetPair, error) {
+	return scanAssetPair(db.QueryRowContext(ctx,
+		"SELECT "+pairColumns+` FROM pairs
+		WHERE name = ? COLLATE NOCASE OR altname = ? COLLATE NOCASE OR wsname = ? COLLATE NOCASE
+		LIMIT 1`,
+		name, name, name))
+}
+
+// RefreshPairs récupère le catalogue complet des paires et l'enregistre
+func RefreshPairs(ctx context.Context, client *KrakenClient, db *sql.DB) (int, error) {
+	pairs, err := client.GetAllAssetPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := SavePairs(ctx, db, pairs); err != nil {
+		return 0, err
+	}
+	return len(pairs), nil
+}
+
+// RefreshCatalogContinuously rafraîchit les catalogues des actifs et des paires
+// au démarrage puis à intervalles réguliers, jusqu'à l'annulation de ctx
+func RefreshCatalogContinuously(ctx context.Context, client *KrakenClient, db *sql.DB, interval time.Duration, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	refresh := func() {
+		assets, err := RefreshAssets(ctx, client, db)
+		if err != nil {
+			log.Println("Erreur lors du rafraîchissement du catalogue des actifs:", err)
+		}
+		pairs, err := RefreshPairs(ctx, client, db)
+		if err != nil {
+			log.Println("Erreur lors du rafraîchissement du catalogue des paires:", err)
+			return
+		}
+		log.Printf("Catalogue rafraîchi: %d actif(s), %d paire(s)", assets, pairs)
+	}
+	refresh()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			refresh()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ------------------- Requêtes sur l'historique -------------------
+
+// Limites de pagination pour les requêtes sur l'historique
+const (
+	defaultHistoryLimit = 1000
+	maxHistoryLimit     = 10000
+)
+
+// Structure représentant un relevé archivé : le ticker Kraken complet.
+// Volume est le volume sur 24h, High et Low ceux de la journée en cours.
+// Les exchanges qui ne fournissent pas certaines valeurs les laissent vides.
+type Tick struct {
+	ID                int64
+	Exchange          string
+	Pair              string
+	Ask               Decimal
+	AskWholeLotVolume Decimal
+	AskLotVolume      Decimal
+	Bid               Decimal
+	BidWholeLotVolume Decimal
+	BidLotVolume      Decimal
+	Last              Decimal
+	LastVolume        Decimal
+	Open              Decimal // Prix d'ouverture de la journée
+	Volume            Decimal
+	VolumeToday       Decimal
+	High              Decimal
+	High24h           Decimal
+	Low               Decimal
+	Low24h            Decimal
+	VWAPToday         Decimal
+	VWAP24h           Decimal
+	TradesToday       Decimal // Nombre de trades (entier)
+	Trades24h         Decimal
+	Timestamp         string
+}
+
+// Colonne d'un relevé, sélectionnable dans les sorties JSON et CSV (?columns=)
+type tickColumn struct {
+	Name   string                    // Clé JSON et nom accepté par ?columns=
+	Header string                    // En-tête CSV
+	SQL    string                    // Colonne de crypto_history
+	field  func(t *Tick) interface{} // Adresse du champ correspondant de Tick
+}
+
+// Colonnes des relevés, dans l'ordre des sorties. Les huit premières sont
+// celles des exports historiques et gardent leur position dans les CSV.
+var tickColumns = []tickColumn{
+	{"pair", "Pair", "pair", func(t *Tick) interface{} { return &t.Pair }},
+	{"ask", "Ask", "ask_price", func(t *Tick) interface{} { return &t.Ask }},
+	{"bid", "Bid", "bid_price", func(t *Tick) interface{} { return &t.Bid }},
+	{"last", "Last", "last_trade_price", func(t *Tick) interface{} { return &t.Last }},
+	{"volume", "Volume", "volume", func(t *Tick) interface{} { return &t.Volume }},
+	{"high", "High", "high_price", func(t *Tick) interface{} { return &t.High }},
+	{"low", "Low", "low_price", func(t *Tick) interface{} { return &t.Low }},
+	{"timestamp", "Timestamp", "timestamp", func(t *Tick) interface{} { return &t.Timestamp }},
+	{"ask_whole_lot_volume", "AskWholeLotVolume", "ask_whole_lot_volume", func(t *Tick) interface{} { return &t.AskWholeLotVolume }},
+	{"ask_lot_volume", "AskLotVolume", "ask_lot_volume", func(t *Tick) interface{} { return &t.AskLotVolume }},
+	{"bid_whole_lot_volume", "BidWholeLotVolume", "bid_whole_lot_volume", func(t *Tick) interface{} { return &t.BidWholeLotVolume }},
+	{"bid_lot_volume", "BidLotVolume", "bid_lot_volume", func(t *Tick) interface{} { return &t.BidLotVolume }},
+	{"last_volume", "LastVolume", "last_trade_volume", func(t *Tick) interface{} { return &t.LastVolume }},
+	{"open", "Open", "open_price", func(t *Tick) interface{} { return &t.Open }},
+	{"volume_today", "VolumeToday", "volume_today", func(t *Tick) interface{} { return &t.VolumeToday }},
+	{"high_24h", "High24h", "high_24h", func(t *Tick) interface{} { return &t.High24h }},
+	{"low_24h", "Low24h", "low_24h", func(t *Tick) interface{} { return &t.Low24h }},
+	{"vwap_today", "VWAPToday", "vwap_today", func(t *Tick) interface{} { return &t.VWAPToday }},
+	{"vwap_24h", "VWAP24h", "vwap_24h", func(t *Tick) interface{} { return &t.VWAP24h }},
+	{"trades_today", "TradesToday", "trades_today", func(t *Tick) interface{} { return &t.TradesToday }},
+	{"trades_24h", "Trades24h", "trades_24h", func(t *Tick) interface{} { return &t.Trades24h }},
+	{"exchange", "Exchange", "exchange", func(t *Tick) interface{} { return &t.Exchange }},
+}
+
+// tickSelectColumns liste les colonnes SQL de tous les champs d'un relevé, dans l'ordre de tickColumns
+func tickSelectColumns() string {
+	names := make([]string, len(tickColumns))
+	for i, c := range tickColumns {
+		names[i] = c.SQL
+	}
+	return strings.Join(names, ", ")
+}
+
+// scanTick lit un relevé sélectionné avec tickSelectColumns, précédé des destinations extra
+func scanTick(rows *sql.Rows, t *Tick, extra ...interface{}) error {
+	dest := extra
+	for _, c := range tickColumns {
+		dest = append(dest, c.field(t))
+	}
+	return rows.Scan(dest...)
+}
+
+// value retourne la valeur de la colonne pour un relevé
+func (c tickColumn) value(t *Tick) interface{} {
+	switch v := c.field(t).(type) {
+	case *string:
+		return *v
+	case *Decimal:
+		return *v
+	}
+	return nil
+}
+
+// csvValue retourne la valeur exacte de la colonne sous forme de texte
+func (c tickColumn) csvValue(t *Tick) string {
+	return fmt.Sprint(c.value(t))
+}
+
+// parseTickColumns lit la sélection de colonnes ?columns=ask,bid,vwap_24h (toutes par défaut)
+func parseTickColumns(values url.Values) ([]tickColumn, error) {
+	names := splitList(values.Get("columns"))
+	if len(names) == 0 {
+		return tickColumns, nil
+	}
+	var columns []tickColumn
+	for _, name := range names {
+		column, ok := findTickColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("colonne inconnue: %q", name)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// findTickColumn retrouve une colonne par son nom (sans tenir compte de la casse)
+func findTickColumn(name string) (tickColumn, bool) {
+	for _, c := range tickColumns {
+		if strings.EqualFold(c.Name, name) {
+			return c, true
+		}
+	}
+	return tickColumn{}, false
+}
+
+// tickJSON construit l'objet JSON d'un relevé limité aux colonnes demandées.
+// La forme canonique de la paire (symbol) accompagne la colonne pair.
+func tickJSON(t *Tick, columns []tickColumn, symbol string) map[string]interface{} {
+	entry := make(map[string]interface{}, len(columns)+1)
+	for _, c := range columns {
+		entry[c.Name] = c.value(t)
+		if c.Name == "pair" && symbol != "" {
+			entry["symbol"] = symbol
+		}
+	}
+	return entry
+}
+
+// tickCSVHeaders retourne l'en-tête CSV des colonnes demandées
+func tickCSVHeaders(columns []tickColumn) []string {
+	headers := make([]string, len(columns))
+	for i, c := range columns {
+		headers[i] = c.Header
+	}
+	return headers
+}
+
+// tickCSVRecord retourne la ligne CSV d'un relevé, avec les valeurs exactes fournies par Kraken
+func tickCSVRecord(t *Tick, columns []tickColumn) []string {
+	record := make([]string, len(columns))
+	for i, c := range columns {
+		record[i] = c.csvValue(t)
+	}
+	return record
+}
+
+// Paramètres d'une requête sur l'historique d'une paire
+type HistoryQuery struct {
+	Exchange   string // Tous les exchanges si vide
+	Pair       string
+	From       time.Time // Inclus, ignoré si nul
+	To         time.Time // Inclus, ignoré si nul
+	Limit      int
+	Descending bool
+	Cursor     string // Curseur opaque retourné par la page précédente
+}
+
+// Page de résultats avec le curseur de la page suivante (vide s'il n'y en a pas)
+type HistoryPage struct {
+	Data       []Tick
+	NextCursor string
+}
+
+// Position dans l'historique : le curseur encode le dernier (timestamp, id) retourné
+type historyCursor struct {
+	Timestamp string
+	ID        int64
+}
+
+func encodeHistoryCursor(c historyCursor) string {
+	raw := fmt.Sprintf("%s|%d", c.Timestamp, c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeHistoryCursor(s string) (historyCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return historyCursor{}, fmt.Errorf("curseur invalide")
+	}
+	timestamp, idStr, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return historyCursor{}, fmt.Errorf("curseur invalide")
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return historyCursor{}, fmt.Errorf("curseur invalide")
+	}
+	return historyCursor{Timestamp: timestamp, ID: id}, nil
+}
+
+// QueryHistory retourne une page de relevés d'une paire, triés par date.
+// La pagination se fait par curseur sur (timestamp, id), ce qui reste stable
+// même si de nouveaux relevés sont insérés entre deux pages.
+func QueryHistory(ctx context.Context, db *sql.DB, q HistoryQuery) (*HistoryPage, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	conditions := []string{"pair = ?"}
+	args := []interface{}{q.Pair}
+
+	if q.Exchange != "" {
+		conditions = append(conditions, "exchange = ?")
+		args = append(args, q.Exchange)
+	}
+	if !q.From.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, q.From.UTC().Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		conditions = append(conditions, "timestamp <= ?")
+		args = append(args, q.To.UTC().Format(time.RFC3339))
+	}
+
+	direction, comparator := "ASC", ">"
+	if q.Descending {
+		direction, comparator = "DESC", "<"
+	}
+
+	if q.Cursor != "" {
+		cursor, err := decodeHistoryCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions,
+			fmt.Sprintf("(timestamp %[1]s ? OR (timestamp = ? AND id %[1]s ?))", comparator))
+		args = append(args, cursor.Timestamp, cursor.Timestamp, cursor.ID)
+	}
+
+	// Une ligne de plus que la limite pour savoir s'il existe une page suivante
+	query := fmt.Sprintf(`SELECT id, %s
+		FROM crypto_history
+		WHERE %s
+		ORDER BY timestamp %s, id %s
+		LIMIT ?`, tickSelectColumns(), strings.Join(conditions, " AND "), direction, direction)
+	args = append(args, limit+1)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &HistoryPage{Data: []Tick{}}
+	for rows.Next() {
+		var t Tick
+		if err := scanTick(rows, &t, &t.ID); err != nil {
+			return nil, err
+		}
+		page.Data = append(page.Data, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Data) > limit {
+		page.Data = page.Data[:limit]
+		last := page.Data[limit-1]
+		page.NextCursor = encodeHistoryCursor(historyCursor{Timestamp: last.Timestamp, ID: last.ID})
+	}
+	return page, nil
+}
+
+// Paramètres d'URL déclenchant une requête sur l'historique
+var historyParams = []string{"from", "to", "limit", "order", "cursor"}
+
+// hasHistoryParams indique si la requête HTTP demande l'historique plutôt que le dernier relevé
+func hasHistoryParams(values url.Values) bool {
+	for _, name := range historyParams {
+		if values.Has(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimeRangeParams lit les paramètres d'URL from et to (nuls s'ils sont absents)
+func parseTimeRangeParams(values url.Values) (from, to time.Time, err error) {
+	if value := values.Get("from"); value != "" {
+		if from, err = parseTimeParam(value); err != nil {
+			return from, to, fmt.Errorf("paramètre from invalide: %v", err)
+		}
+	}
+	if value := values.Get("to"); value != "" {
+		if to, err = parseTimeParam(value); err != nil {
+			return from, to, fmt.Errorf("paramètre to invalide: %v", err)
+		}
+	}
+	return from, to, nil
+}
+
+// parseHistoryQuery construit une requête sur l'historique à partir des paramètres d'URL
+func parseHistoryQuery(pair string, values url.Values) (HistoryQuery, error) {
+	q := HistoryQuery{Exchange: values.Get("exchange"), Pair: pair, Cursor: values.Get("cursor")}
+	var err error
+
+	if q.Cursor != "" {
+		if _, err := decodeHistoryCursor(q.Cursor); err != nil {
+			return q, err
+		}
+	}
+
+	if q.From, q.To, err = parseTimeRangeParams(values); err != nil {
+		return q, err
+	}
+	if limit := values.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit <= 0 {
+			return q, fmt.Errorf("paramètre limit invalide: %q", limit)
+		}
+	}
+
+	switch order := values.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		q.Descending = true
+	default:
+		return q, fmt.Errorf("paramètre order invalide: %q (attendu: asc ou desc)", order)
+	}
+	return q, nil
+}
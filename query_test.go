@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseHistoryQuery(t *testing.T) {
+	q, err := parseHistoryQuery("XBTUSD", url.Values{
+		"from": {"2025-01-01"}, "to": {"1735732800"}, "limit": {"50"}, "order": {"desc"}, "exchange": {"binance"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.From.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) || q.To.Unix() != 1735732800 ||
+		q.Limit != 50 || !q.Descending || q.Exchange != "binance" || q.Pair != "XBTUSD" {
+		t.Errorf("requête = %+v", q)
+	}
+
+	for _, values := range []url.Values{
+		{"from": {"hier"}},
+		{"limit": {"0"}},
+		{"limit": {"abc"}},
+		{"order": {"up"}},
+		{"cursor": {"pas-un-curseur"}},
+	} {
+		if _, err := parseHistoryQuery("XBTUSD", values); err == nil {
+			t.Errorf("%v accepté, attendu une erreur", values)
+		}
+	}
+}
+
+func TestQueryHistoryPagination(t *testing.T) {
+	db := InitDB(filepath.Join(t.TempDir(), "crypto.db"))
+	defer db.Close()
+	ctx := context.Background()
+
+	// Cinq relevés dont deux à la même seconde : le curseur départage par identifiant
+	for _, ts := range []string{"2025-01-01T00:00:00Z", "2025-01-01T00:01:00Z", "2025-01-01T00:01:00Z", "2025-01-01T00:02:00Z", "2025-01-01T00:03:00Z"} {
+		InsertCryptoData(ctx, db, &Tick{Exchange: "kraken", Pair: "XBTUSD", Ask: "1", Timestamp: ts})
+	}
+	InsertCryptoData(ctx, db, &Tick{Exchange: "kraken", Pair: "ETHUSD", Ask: "1", Timestamp: "2025-01-01T00:01:30Z"})
+
+	collect := func(q HistoryQuery) []int64 {
+		var ids []int64
+		for pages := 0; pages < 10; pages++ {
+			page, err := QueryHistory(ctx, db, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, tick := range page.Data {
+				ids = append(ids, tick.ID)
+			}
+			if page.NextCursor == "" {
+				return ids
+			}
+			q.Cursor = page.NextCursor
+		}
+		t.Fatal("pagination sans fin")
+		return nil
+	}
+
+	if ids := collect(HistoryQuery{Pair: "XBTUSD", Limit: 2}); len(ids) != 5 || ids[0] != 1 || ids[4] != 5 {
+		t.Errorf("ordre croissant = %v, attendu 1..5", ids)
+	}
+	if ids := collect(HistoryQuery{Pair: "XBTUSD", Limit: 2, Descending: true}); len(ids) != 5 || ids[0] != 5 || ids[1] != 4 || ids[2] != 3 {
+		t.Errorf("ordre décroissant = %v, attendu 5..1", ids)
+	}
+
+	from := time.Date(2025, 1, 1, 0, 1, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 1, 0, 2, 0, 0, time.UTC)
+	if ids := collect(HistoryQuery{Pair: "XBTUSD", From: from, To: to, Limit: 1}); len(ids) != 3 || ids[0] != 2 || ids[2] != 4 {
+		t.Errorf("plage [00:01, 00:02] = %v, attendu 2, 3, 4", ids)
+	}
+}
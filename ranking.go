@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+)
+
+// ------------------- Classement des paires par volume -------------------
+
+// Devise de référence par défaut du classement
+const defaultRankingCurrency = "USD"
+
+// Volume d'une paire sur 24h, exprimé en actif de base, en devise de cotation
+// et converti dans la devise de référence du classement
+type PairRanking struct {
+	Rank            int     `json:"rank"`
+	Pair            string  `json:"pair"`   // Nom alternatif (XBTUSD)
+	Symbol          string  `json:"symbol"` // Forme canonique (BTC/USD)
+	Base            string  `json:"base"`
+	Quote           string  `json:"quote"`
+	Last            Decimal `json:"last"`
+	Volume          Decimal `json:"volume"`           // En actif de base
+	QuoteVolume     float64 `json:"quote_volume"`     // En devise de cotation
+	ConvertedVolume float64 `json:"converted_volume"` // En devise de référence (0 sans taux de conversion)
+}
+
+// Classement des paires dans une devise de référence
+type Ranking struct {
+	Currency string             `json:"currency"`
+	RankedAt time.Time          `json:"ranked_at"`
+	Rates    map[string]float64 `json:"rates"` // Valeur d'une unité de chaque actif dans la devise de référence
+	Pairs    []PairRanking      `json:"pairs"`
+}
+
+// fetchMarket récupère les tickers de toutes les paires et calcule leurs volumes en devise
+// de cotation. Les tickers sont demandés en une seule requête (GetTickers sans liste de
+// paires) : par lots de 10 paires, le classement prendrait plus d'une minute au débit
+// autorisé par Kraken, au-delà de l'échéance d'un cycle d'archivage.
+// En cas d'erreur, aucun volume n'est retourné.
+func (c *KrakenClient) fetchMarket(ctx context.Context, pairs []PairMapping) ([]PairRanking, error) {
+	tickers, err := c.GetTickers(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var market []PairRanking
+	for _, pair := range pairs {
+		ticker, ok := tickers[pair.InternalName]
+		if !ok {
+			continue
+		}
+		entry := PairRanking{
+			Pair:   pair.AltName,
+			Base:   pair.Base,
+			Quote:  pair.Quote,
+			Last:   ticker.Last.Decimal(0),
+			Volume: ticker.Volume.Decimal(1), // Volume sur 24h
+		}
+		if entry.Base != "" && entry.Quote != "" {
+			entry.Symbol = entry.Base + "/" + entry.Quote
+		}
+		entry.QuoteVolume = entry.Volume.Float64() * entry.Last.Float64()
+		market = append(market, entry)
+	}
+
+	c.marketMu.Lock()
+	c.market = market
+	c.marketAt = time.Now()
+	c.marketMu.Unlock()
+	return market, nil
+}
+
+// marketFromTicks calcule les volumes en devise de cotation à partir des relevés
+// d'un exchange, indexés par nom stocké
+func marketFromTicks(pairs []PairMapping, ticks map[string]*Tick) []PairRanking {
+	var market []PairRanking
+	for _, pair := range pairs {
+		tick, ok := ticks[pair.AltName]
+		if !ok {
+			continue
+		}
+		entry := PairRanking{
+			Pair:   pair.AltName,
+			Base:   pair.Base,
+			Quote:  pair.Quote,
+			Last:   tick.Last,
+			Volume: tick.Volume, // Volume sur 24h
+		}
+		if entry.Base != "" && entry.Quote != "" {
+			entry.Symbol = entry.Base + "/" + entry.Quote
+		}
+		entry.QuoteVolume = entry.Volume.Float64() * entry.Last.Float64()
+		market = append(market, entry)
+	}
+	return market
+}
+
+// crossRates calcule la valeur de chaque actif dans la devise de référence à partir
+// des derniers prix : directement (BTC/USD), puis par rebonds successifs (ADA/BTC
+// via BTC/USD, ou EUR via BTC/EUR et BTC/USD). Les conversions directes sont prioritaires.
+func crossRates(market []PairRanking, currency string) map[string]float64 {
+	rates := map[string]float64{currency: 1}
+	for {
+		found := make(map[string]float64)
+		for _, entry := range market {
+			price := entry.Last.Float64()
+			if price <= 0 || entry.Base == "" || entry.Quote == "" {
+				continue
+			}
+			quoteRate, quoteKnown := rates[entry.Quote]
+			baseRate, baseKnown := rates[entry.Base]
+			if _, ok := found[entry.Base]; quoteKnown && !baseKnown && !ok {
+				found[entry.Base] = price * quoteRate
+			}
+			if _, ok := found[entry.Quote]; baseKnown && !quoteKnown && !ok {
+				found[entry.Quote] = baseRate / price
+			}
+		}
+		if len(found) == 0 {
+			return rates
+		}
+		for asset, rate := range found {
+			rates[asset] = rate
+		}
+	}
+}
+
+// rankMarket classe les paires par volume converti dans la devise de référence
+func rankMarket(market []PairRanking, currency string, rankedAt time.Time) *Ranking {
+	currency = normalizeSymbol(currency)
+	if currency == "" {
+		currency = defaultRankingCurrency
+	}
+
+	// Ordre stable des paires : les taux ne dépendent pas de l'ordre des tickers reçus
+	pairs := make([]PairRanking, len(market))
+	copy(pairs, market)
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Pair < pairs[j].Pair
+	})
+
+	rates := crossRates(pairs, currency)
+	for i := range pairs {
+		pairs[i].ConvertedVolume = pairs[i].QuoteVolume * rates[pairs[i].Quote]
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].ConvertedVolume > pairs[j].ConvertedVolume
+	})
+	for i := range pairs {
+		pairs[i].Rank = i + 1
+	}
+	return &Ranking{Currency: currency, RankedAt: rankedAt, Rates: rates, Pairs: pairs}
+}
+
+// marketPairs retourne toutes les paires du catalogue Kraken avec leurs symboles usuels,
+// triées par nom interne : les requêtes qui en découlent sont identiques d'un appel à
+// l'autre, ce dont dépend le rejeu des réponses enregistrées
+func (c *KrakenClient) marketPairs(ctx context.Context) ([]PairMapping, error) {
+	assetPairs, err := c.GetAllAssetPairs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pairs []PairMapping
+	for internalName, pair := range assetPairs {
+		if pair.AltName == "" {
+			continue
+		}
+		ps := newPairSymbol(internalName, pair.AltName, pair.WSName, "", "")
+		pairs = append(pairs, PairMapping{
+			InternalName: internalName,
+			AltName:      pair.AltName,
+			WSName:       pair.WSName,
+			Base:         ps.Base,
+			Quote:        ps.Quote,
+		})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].InternalName < pairs[j].InternalName
+	})
+	return pairs, nil
+}
+
+// GetRanking retourne le classement de toutes les paires par volume sur 24h converti
+// dans la devise demandée. Les tickers sont réutilisés pendant RankingTTL ; si leur
+// rafraîchissement est incomplet, les précédents sont conservés.
+func (c *KrakenClient) GetRanking(ctx context.Context, currency string) (*Ranking, error) {
+	c.marketMu.Lock()
+	market, marketAt := c.market, c.marketAt
+	c.marketMu.Unlock()
+
+	if market == nil || time.Since(marketAt) >= c.RankingTTL {
+		pairs, err := c.marketPairs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		fetched, err := c.fetchMarket(ctx, pairs)
+		switch {
+		case err == nil:
+			market, marketAt = fetched, time.Now()
+		case market != nil:
+			log.Printf("Tickers incomplets, classement précédent conservé: %v", err)
+		default:
+			log.Printf("Tickers incomplets, classement partiel: %v", err)
+			market, marketAt = fetched, time.Now()
+		}
+	}
+	return rankMarket(market, currency, marketAt), nil
+}
+
+// knownAsset indique si l'actif figure dans l'une des paires du classement
+func (r *Ranking) knownAsset(asset string) bool {
+	for _, pair := range r.Pairs {
+		if pair.Base == asset || pair.Quote == asset {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------- Limitation du débit des appels Kraken -------------------
+
+// Limite globale par défaut : Kraken autorise environ une requête publique par seconde
+// et par IP, avec une petite réserve pour absorber les rafales.
+const (
+	defaultRateLimit = 1.0
+	defaultRateBurst = 10.0
+)
+
+// Plancher du débit après des erreurs de dépassement, en fraction du débit nominal
+const minRateFactor = 1.0 / 16
+
+// Configuration d'un seau de jetons : débit (requêtes par seconde) et réserve maximale
+type RateLimit struct {
+	Rate  float64
+	Burst float64
+}
+
+// ParseRateLimit lit une limite au format "débit:réserve" (ex: "1:10" ou "0.5:2")
+func ParseRateLimit(value string) (RateLimit, error) {
+	rateStr, burstStr, ok := strings.Cut(value, ":")
+	if !ok {
+		burstStr = "1"
+	}
+	rate, err := strconv.ParseFloat(rateStr, 64)
+	if err != nil || rate <= 0 {
+		return RateLimit{}, fmt.Errorf("débit invalide: %q", value)
+	}
+	burst, err := strconv.ParseFloat(burstStr, 64)
+	if err != nil || burst < 1 {
+		return RateLimit{}, fmt.Errorf("réserve invalide: %q", value)
+	}
+	return RateLimit{Rate: rate, Burst: burst}, nil
+}
+
+// ParseEndpointRateLimits lit des limites par endpoint au format "OHLC=0.5:2,Trades=1:5"
+func ParseEndpointRateLimits(value string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	if value == "" {
+		return limits, nil
+	}
+	for _, item := range strings.Split(value, ",") {
+		endpoint, limitStr, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("limite invalide: %q (attendu: Endpoint=débit:réserve)", item)
+		}
+		limit, err := ParseRateLimit(limitStr)
+		if err != nil {
+			return nil, err
+		}
+		limits[endpoint] = limit
+	}
+	return limits, nil
+}
+
+// Seau de jetons avec adaptation du débit (baisse de moitié à chaque dépassement
+// signalé par Kraken, remontée progressive à chaque succès)
+type tokenBucket struct {
+	name     string
+	baseRate float64
+	rate     float64
+	burst    float64
+	tokens   float64
+	last     time.Time
+
+	requests        int64
+	waits           int64
+	waitTime        time.Duration
+	rateLimitErrors int64
+}
+
+func newTokenBucket(name string, limit RateLimit) *tokenBucket {
+	return &tokenBucket{
+		name:     name,
+		baseRate: limit.Rate,
+		rate:     limit.Rate,
+		burst:    limit.Burst,
+		tokens:   limit.Burst,
+		last:     time.Now(),
+	}
+}
+
+// refill ajoute les jetons accumulés depuis le dernier passage
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
+
+// delay retourne l'attente nécessaire avant de disposer d'un jeton
+func (b *tokenBucket) delay() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Limiteur partagé par tous les appels Kraken : un seau global et, pour les
+// endpoints configurés, un seau dédié. Une requête consomme un jeton de chacun.
+type RateLimiter struct {
+	mu        sync.Mutex
+	global    *tokenBucket
+	endpoints map[string]*tokenBucket
+}
+
+// NewRateLimiter crée un limiteur avec une limite globale et des limites par endpoint
+func NewRateLimiter(global RateLimit, endpoints map[string]RateLimit) *RateLimiter {
+	l := &RateLimiter{
+		global:    newTokenBucket("global", global),
+		endpoints: make(map[string]*tokenBucket),
+	}
+	for name, limit := range endpoints {
+		l.endpoints[name] = newTokenBucket(name, limit)
+	}
+	return l
+}
+
+// buckets retourne les seaux concernés par un endpoint
+func (l *RateLimiter) buckets(endpoint string) []*tokenBucket {
+	buckets := []*tokenBucket{l.global}
+	if b, ok := l.endpoints[endpoint]; ok {
+		buckets = append(buckets, b)
+	}
+	return buckets
+}
+
+// Wait bloque jusqu'à ce qu'une requête vers l'endpoint soit autorisée,
+// ou retourne l'erreur du contexte s'il est annulé avant
+func (l *RateLimiter) Wait(ctx context.Context, endpoint string) error {
+	waited := false
+	start := time.Now()
+
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		buckets := l.buckets(endpoint)
+		var delay time.Duration
+		for _, b := range buckets {
+			b.refill(now)
+			if d := b.delay(); d > delay {
+				delay = d
+			}
+		}
+
+		if delay == 0 {
+			for _, b := range buckets {
+				b.tokens--
+				b.requests++
+				if waited {
+					b.waits++
+					b.waitTime += now.Sub(start)
+				}
+			}
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		waited = true
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// RateLimited signale une erreur "EAPI:Rate limit exceeded" : le débit est divisé
+// par deux et la réserve vidée pour laisser le compteur de Kraken redescendre.
+func (l *RateLimiter) RateLimited(endpoint string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, b := range l.buckets(endpoint) {
+		b.rateLimitErrors++
+		b.rate /= 2
+		if min := b.baseRate * minRateFactor; b.rate < min {
+			b.rate = min
+		}
+		b.tokens = 0
+	}
+}
+
+// Succeeded signale une requête acceptée : le débit remonte progressivement vers le nominal
+func (l *RateLimiter) Succeeded(endpoint string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, b := range l.buckets(endpoint) {
+		if b.rate < b.baseRate {
+			b.rate += b.baseRate / 20
+			if b.rate > b.baseRate {
+				b.rate = b.baseRate
+			}
+		}
+	}
+}
+
+// Structure exposant l'état d'un seau dans les métriques
+type RateLimiterStats struct {
+	Bucket          string  `json:"bucket"`
+	Rate            float64 `json:"rate"`      // Débit autorisé actuellement (requêtes/s)
+	BaseRate        float64 `json:"base_rate"` // Débit nominal configuré
+	Burst           float64 `json:"burst"`
+	Tokens          float64 `json:"tokens"`
+	Throttled       bool    `json:"throttled"` // Débit réduit suite à des dépassements
+	Requests        int64   `json:"requests"`
+	Waits           int64   `json:"waits"` // Requêtes ayant dû attendre un jeton
+	WaitSeconds     float64 `json:"wait_seconds"`
+	RateLimitErrors int64   `json:"rate_limit_errors"`
+}
+
+// Stats retourne l'état de chaque seau (global en premier)
+func (l *RateLimiter) Stats() []RateLimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	buckets := []*tokenBucket{l.global}
+	var names []string
+	for name := range l.endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		buckets = append(buckets, l.endpoints[name])
+	}
+
+	now := time.Now()
+	var stats []RateLimiterStats
+	for _, b := range buckets {
+		b.refill(now)
+		stats = append(stats, RateLimiterStats{
+			Bucket:          b.name,
+			Rate:            b.rate,
+			BaseRate:        b.baseRate,
+			Burst:           b.burst,
+			Tokens:          b.tokens,
+			Throttled:       b.rate < b.baseRate,
+			Requests:        b.requests,
+			Waits:           b.waits,
+			WaitSeconds:     b.waitTime.Seconds(),
+			RateLimitErrors: b.rateLimitErrors,
+		})
+	}
+	return stats
+}
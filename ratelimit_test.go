@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	if limit, err := ParseRateLimit("0.5:2"); err != nil || limit != (RateLimit{Rate: 0.5, Burst: 2}) {
+		t.Errorf("ParseRateLimit(0.5:2) = %+v, %v", limit, err)
+	}
+	if limit, err := ParseRateLimit("3"); err != nil || limit != (RateLimit{Rate: 3, Burst: 1}) {
+		t.Errorf("ParseRateLimit(3) = %+v, %v", limit, err)
+	}
+	for _, value := range []string{"", "0", "-1:2", "1:0.5", "abc:1"} {
+		if _, err := ParseRateLimit(value); err == nil {
+			t.Errorf("ParseRateLimit(%q) accepté", value)
+		}
+	}
+
+	limits, err := ParseEndpointRateLimits("OHLC=0.5:2,Trades=1:5")
+	if err != nil || len(limits) != 2 || limits["OHLC"] != (RateLimit{Rate: 0.5, Burst: 2}) {
+		t.Errorf("ParseEndpointRateLimits = %v, %v", limits, err)
+	}
+	if _, err := ParseEndpointRateLimits("OHLC"); err == nil {
+		t.Error("limite sans endpoint acceptée")
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	limiter := NewRateLimiter(RateLimit{Rate: 20, Burst: 2}, map[string]RateLimit{"OHLC": {Rate: 10, Burst: 1}})
+	ctx := context.Background()
+
+	// La réserve globale permet deux requêtes immédiates, la troisième attend un jeton (50ms)
+	start := time.Now()
+	for range 3 {
+		if err := limiter.Wait(ctx, "Ticker"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond || elapsed > time.Second {
+		t.Errorf("3 requêtes en %v, attendu environ 50ms", elapsed)
+	}
+
+	// Le seau de l'endpoint s'ajoute au seau global : deux requêtes OHLC espacées de 100ms
+	time.Sleep(100 * time.Millisecond)
+	start = time.Now()
+	limiter.Wait(ctx, "OHLC")
+	limiter.Wait(ctx, "OHLC")
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("2 requêtes OHLC en %v, attendu environ 100ms", elapsed)
+	}
+
+	// Une requête qui a attendu est comptée dans tous ses seaux
+	stats := limiter.Stats()
+	if len(stats) != 2 || stats[0].Bucket != "global" || stats[0].Requests != 5 || stats[0].Waits != 2 ||
+		stats[1].Bucket != "OHLC" || stats[1].Requests != 2 || stats[1].Waits != 1 {
+		t.Errorf("statistiques = %+v", stats)
+	}
+
+	// Annulation pendant l'attente
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(waitCtx, "OHLC"); err != context.DeadlineExceeded {
+		t.Errorf("Wait annulé = %v, attendu context.DeadlineExceeded", err)
+	}
+}
+
+func TestRateLimiterRateLimited(t *testing.T) {
+	limiter := NewRateLimiter(RateLimit{Rate: 16, Burst: 10}, map[string]RateLimit{"OHLC": {Rate: 2, Burst: 2}})
+
+	// Un dépassement divise le débit par deux et vide la réserve des seaux concernés
+	limiter.RateLimited("OHLC")
+	stats := limiter.Stats()
+	if stats[0].Rate != 8 || stats[0].Tokens > 1 || !stats[0].Throttled || stats[0].RateLimitErrors != 1 || stats[1].Rate != 1 {
+		t.Errorf("après un dépassement = %+v", stats)
+	}
+	limiter.RateLimited("Ticker")
+	if stats := limiter.Stats(); stats[0].Rate != 4 || stats[1].Rate != 1 || stats[1].RateLimitErrors != 1 {
+		t.Errorf("après un dépassement sur Ticker = %+v", stats)
+	}
+
+	// Le débit ne descend pas sous 1/16 du nominal
+	for range 10 {
+		limiter.RateLimited("Ticker")
+	}
+	if rate := limiter.Stats()[0].Rate; rate != 1 {
+		t.Errorf("débit plancher = %g, attendu 1", rate)
+	}
+
+	// Chaque succès le fait remonter de 1/20 du nominal, jusqu'au nominal
+	limiter.Succeeded("Ticker")
+	if rate := limiter.Stats()[0].Rate; rate != 1.8 {
+		t.Errorf("débit après un succès = %g, attendu 1.8", rate)
+	}
+	for range 30 {
+		limiter.Succeeded("Ticker")
+	}
+	if stats := limiter.Stats(); stats[0].Rate != 16 || stats[0].Throttled {
+		t.Errorf("débit après 30 succès = %+v", stats[0])
+	}
+}
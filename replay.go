@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------- Enregistrement et rejeu des réponses Kraken -------------------
+
+// Réponse HTTP brute de l'API Kraken, telle qu'enregistrée dans un fichier JSON
+type RecordedResponse struct {
+	Seq       int       `json:"seq"`
+	Timestamp time.Time `json:"timestamp"` // Réception de la réponse
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`  // Ex: /0/public/Ticker
+	Query     string    `json:"query"` // Paramètres encodés, triés par nom
+	Status    int       `json:"status,omitempty"`
+	Body      string    `json:"body,omitempty"`
+	Error     string    `json:"error,omitempty"` // Erreur réseau (aucune réponse reçue)
+}
+
+// recordingFileName nomme le fichier d'une réponse : numéro d'ordre puis endpoint
+// (000042_Ticker.json), pour que l'ordre alphabétique soit l'ordre des appels
+func recordingFileName(seq int, path string) string {
+	return fmt.Sprintf("%06d_%s.json", seq, path[strings.LastIndex(path, "/")+1:])
+}
+
+// Transport HTTP qui enregistre chaque réponse dans un dossier avant de la transmettre.
+// Installé sur le client Kraken, il capture tous les appels à l'API, réessais compris.
+type RecordingTransport struct {
+	Base http.RoundTripper
+	Dir  string
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewRecordingTransport crée le dossier d'enregistrement si besoin. Les numéros
+// d'ordre reprennent après les réponses déjà enregistrées dans le dossier.
+func NewRecordingTransport(dir string, base http.RoundTripper) (*RecordingTransport, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	existing, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RecordingTransport{Base: base, Dir: dir, seq: len(existing)}, nil
+}
+
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Base.RoundTrip(req)
+
+	record := RecordedResponse{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  req.URL.Query().Encode(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	} else {
+		body, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if readErr != nil {
+			return nil, readErr
+		}
+		// La réponse est transmise intacte au client
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		record.Status = resp.StatusCode
+		record.Body = string(body)
+	}
+	record.Timestamp = time.Now().UTC()
+
+	t.mu.Lock()
+	t.seq++
+	record.Seq = t.seq
+	t.mu.Unlock()
+
+	if data, marshalErr := json.MarshalIndent(record, "", "  "); marshalErr != nil {
+		log.Printf("Erreur lors de l'enregistrement de la réponse %s: %v", record.Path, marshalErr)
+	} else if writeErr := os.WriteFile(filepath.Join(t.Dir, recordingFileName(record.Seq, record.Path)), data, 0644); writeErr != nil {
+		log.Printf("Erreur lors de l'enregistrement de la réponse %s: %v", record.Path, writeErr)
+	}
+	return resp, err
+}
+
+// Transport HTTP qui rejoue des réponses enregistrées au lieu d'appeler l'API.
+// Une requête reçoit la première réponse non consommée enregistrée pour la même méthode,
+// le même endpoint et les mêmes paramètres. Une réponse sautée (appel que le rejeu n'a
+// pas refait à ce moment-là) reste disponible pour une requête ultérieure ; celles qui ne
+// sont jamais rejouées sont signalées par Unused.
+type Replayer struct {
+	mu      sync.Mutex
+	records []RecordedResponse
+	used    []bool
+	cursor  int // Indice suivant la dernière réponse consommée
+}
+
+// LoadRecordings charge les réponses enregistrées dans un dossier
+func LoadRecordings(dir string) (*Replayer, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []RecordedResponse
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var record RecordedResponse
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, fmt.Errorf("enregistrement invalide %s: %v", filepath.Base(file), err)
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("aucune réponse enregistrée dans %s", dir)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Seq < records[j].Seq
+	})
+	return &Replayer{records: records, used: make([]bool, len(records))}, nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	query := req.URL.Query().Encode()
+	match := -1
+	for i, record := range r.records {
+		if !r.used[i] && record.Method == req.Method && record.Path == req.URL.Path && record.Query == query {
+			match = i
+			break
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("aucune réponse enregistrée pour %s %s?%s", req.Method, req.URL.Path, query)
+	}
+	record := r.records[match]
+	r.used[match] = true
+	if match >= r.cursor {
+		r.cursor = match + 1
+	}
+
+	if record.Error != "" {
+		return nil, fmt.Errorf("%s", record.Error)
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", record.Status, http.StatusText(record.Status)),
+		StatusCode: record.Status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(record.Body)),
+		Request:    req,
+	}, nil
+}
+
+// Next retourne la date d'enregistrement de la prochaine réponse à rejouer, la première
+// non consommée après la dernière réponse rejouée
+func (r *Replayer) Next() (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := r.cursor; i < len(r.records); i++ {
+		if !r.used[i] {
+			return r.records[i].Timestamp, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Remaining retourne le nombre de réponses restant à rejouer
+func (r *Replayer) Remaining() int {
+	return len(r.Unused())
+}
+
+// Unused retourne les réponses non consommées, dans l'ordre d'enregistrement
+func (r *Replayer) Unused() []RecordedResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []RecordedResponse
+	for i, record := range r.records {
+		if !r.used[i] {
+			unused = append(unused, record)
+		}
+	}
+	return unused
+}
+
+// ReplayArchive enchaîne des cycles d'archivage alimentés par les réponses enregistrées,
+// jusqu'à leur épuisement ou l'annulation de ctx, et retourne le nombre de cycles.
+// Chaque cycle est daté de sa première réponse enregistrée ; l'attente entre deux cycles
+// est l'intervalle enregistré divisé par speed (aucune attente si speed <= 0).
+func ReplayArchive(ctx context.Context, replayer *Replayer, client *KrakenClient, db *sql.DB, speed float64) int {
+	client.HTTPClient = &http.Client{Transport: replayer}
+	client.Limiter = nil
+	if speed > 0 {
+		// Les délais du client suivent le temps accéléré : la sélection des paires est
+		// réévaluée aux mêmes cycles que lors de l'enregistrement
+		client.RankingTTL = time.Duration(float64(client.RankingTTL) / speed)
+		client.RetryBaseDelay = time.Duration(float64(client.RetryBaseDelay) / speed)
+		client.RetryMaxDelay = time.Duration(float64(client.RetryMaxDelay) / speed)
+	} else {
+		client.RetryBaseDelay, client.RetryMaxDelay = 0, 0
+	}
+	exchange := NewKrakenExchange(client)
+
+	cycles := 0
+	for ctx.Err() == nil {
+		start, ok := replayer.Next()
+		if !ok {
+			break
+		}
+		remaining := replayer.Remaining()
+
+		log.Printf("Rejeu du cycle %d (enregistré le %s)", cycles+1, start.Format(time.RFC3339))
+		ArchiveDataAt(ctx, exchange, db, start)
+		cycles++
+
+		next, ok := replayer.Next()
+		if !ok {
+			break
+		}
+		if replayer.Remaining() == remaining {
+			// Le cycle n'a consommé aucune réponse : les suivantes ne correspondent à aucun appel
+			log.Printf("Rejeu interrompu : %d réponse(s) ne correspondent à aucun appel de l'archivage", remaining)
+			break
+		}
+		if speed > 0 {
+			timer := time.NewTimer(time.Duration(float64(next.Sub(start)) / speed))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+		}
+	}
+	unused := replayer.Unused()
+	log.Printf("Rejeu terminé : %d cycle(s), %d réponse(s) non rejouée(s)", cycles, len(unused))
+	for _, record := range unused {
+		log.Printf("Réponse non rejouée : n°%d %s %s?%s (enregistrée le %s)", record.Seq, record.Method, record.Path, record.Query, record.Timestamp.Format(time.RFC3339))
+	}
+	return cycles
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newKrakenTickerServer simule AssetPairs et Ticker pour XXBTZUSD ; le prix
+// augmente d'une unité à chaque appel du ticker (100, 101, ...)
+func newKrakenTickerServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	calls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/0/public/AssetPairs", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"error":[],"result":{"XXBTZUSD":{"altname":"XBTUSD","wsname":"XBT/USD","base":"XXBT","quote":"ZUSD"}}}`)
+	})
+	mux.HandleFunc("/0/public/Ticker", func(w http.ResponseWriter, r *http.Request) {
+		price := 100 + calls
+		calls++
+		fmt.Fprintf(w, `{"error":[],"result":{"XXBTZUSD":{"a":["%d.5","1","1.000"],"b":["%d.0","2","2.000"],"c":["%d.2","0.1"],`+
+			`"v":["10","20"],"p":["100","100"],"t":[5,10],"l":["90","90"],"h":["110","110"],"o":"95"}}}`, price, price, price)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// archivedAsks retourne les relevés archivés sous la forme "timestamp ask"
+func archivedAsks(t *testing.T, dbPath string) []string {
+	t.Helper()
+	db := InitDB(dbPath)
+	defer db.Close()
+	rows, err := db.Query("SELECT timestamp, ask_price FROM crypto_history ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var timestamp string
+		var ask Decimal
+		if err := rows.Scan(&timestamp, &ask); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, timestamp+" "+string(ask))
+	}
+	return got
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	recordDir := filepath.Join(dir, "recordings")
+	ctx := context.Background()
+
+	// Enregistrement de trois cycles, la sélection étant réévaluée à chaque cycle
+	client := NewKrakenClient(newKrakenTickerServer(t).URL, 5*time.Second)
+	client.Limiter = nil
+	client.RankingTTL = 0
+	client.Universe = PairSelection{Pairs: []string{"XBTUSD"}}
+	recorder, err := NewRecordingTransport(recordDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.HTTPClient.Transport = recorder
+
+	db := InitDB(filepath.Join(dir, "live.db"))
+	for i := 0; i < 3; i++ {
+		ArchiveData(ctx, NewKrakenExchange(client), db)
+	}
+	db.Close()
+
+	files, _ := filepath.Glob(filepath.Join(recordDir, "*.json"))
+	if len(files) != 6 || filepath.Base(files[0]) != "000001_AssetPairs.json" || filepath.Base(files[1]) != "000002_Ticker.json" {
+		t.Fatalf("enregistrements = %v", files)
+	}
+
+	// Espacer les cycles enregistrés d'une minute, comme lors d'un archivage réel
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, file := range files {
+		data, _ := os.ReadFile(file)
+		var record RecordedResponse
+		if err := json.Unmarshal(data, &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Seq != i+1 || record.Status != http.StatusOK || !strings.Contains(record.Body, "XXBTZUSD") {
+			t.Fatalf("enregistrement %s = %+v", file, record)
+		}
+		record.Timestamp = base.Add(time.Duration(i/2)*time.Minute + time.Duration(i%2)*time.Second)
+		data, _ = json.Marshal(record)
+		os.WriteFile(file, data, 0644)
+	}
+
+	replay := func(speed float64) []string {
+		replayer, err := LoadRecordings(recordDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := NewKrakenClient("http://127.0.0.1:0", time.Second)
+		client.RankingTTL = 0
+		client.Universe = PairSelection{Pairs: []string{"XBTUSD"}}
+		dbPath := filepath.Join(t.TempDir(), "replay.db")
+		db := InitDB(dbPath)
+		defer db.Close()
+		if cycles := ReplayArchive(ctx, replayer, client, db, speed); cycles != 3 {
+			t.Errorf("%d cycles rejoués, attendu 3", cycles)
+		}
+		return archivedAsks(t, dbPath)
+	}
+
+	want := []string{
+		"2025-01-01T00:00:00Z 100.5",
+		"2025-01-01T00:01:00Z 101.5",
+		"2025-01-01T00:02:00Z 102.5",
+	}
+	if got := replay(0); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("relevés rejoués:\n%s\nattendu:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Accéléré 1200 fois, deux intervalles d'une minute durent 100ms
+	start := time.Now()
+	if got := replay(1200); len(got) != 3 {
+		t.Errorf("relevés rejoués en accéléré = %v", got)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("rejeu accéléré en %v, attendu au moins 100ms", elapsed)
+	}
+}
+
+func TestReplayerUnknownEndpoint(t *testing.T) {
+	dir := t.TempDir()
+	record, _ := json.Marshal(RecordedResponse{Seq: 1, Method: http.MethodGet, Path: "/0/public/Time", Status: http.StatusOK, Body: `{"error":[],"result":{"unixtime":1}}`})
+	os.WriteFile(filepath.Join(dir, recordingFileName(1, "/0/public/Time")), record, 0644)
+
+	replayer, err := LoadRecordings(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewKrakenClient("http://127.0.0.1:0", time.Second)
+	client.HTTPClient = &http.Client{Transport: replayer}
+	client.MaxRetries = 0
+
+	if _, err := client.GetAllAssetPairs(context.Background()); err == nil || !strings.Contains(err.Error(), "aucune réponse enregistrée") {
+		t.Errorf("erreur = %v", err)
+	}
+	status, err := client.GetServerStatus(context.Background())
+	if err != nil || status.Unixtime != 1 {
+		t.Errorf("GetServerStatus = %+v, %v", status, err)
+	}
+	if replayer.Remaining() != 0 {
+		t.Errorf("%d réponse(s) restantes, attendu 0", replayer.Remaining())
+	}
+}
+
+func TestReplayerMatchesQuery(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, record := range []RecordedResponse{
+		{Method: http.MethodGet, Path: "/0/public/Ticker", Query: "pair=XXBTZUSD", Body: "xbt-1"},
+		{Method: http.MethodGet, Path: "/0/public/Ticker", Query: "pair=XETHZUSD", Body: "eth"},
+		{Method: http.MethodGet, Path: "/0/public/Time", Body: "time"},
+		{Method: http.MethodGet, Path: "/0/public/Ticker", Query: "pair=XXBTZUSD", Body: "xbt-2"},
+	} {
+		record.Seq, record.Status, record.Timestamp = i+1, http.StatusOK, base.Add(time.Duration(i)*time.Minute)
+		data, _ := json.Marshal(record)
+		os.WriteFile(filepath.Join(dir, recordingFileName(record.Seq, record.Path)), data, 0644)
+	}
+
+	replayer, err := LoadRecordings(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: replayer}
+	get := func(query string) string {
+		resp, err := client.Get("http://kraken.test/0/public/Ticker?" + query)
+		if err != nil {
+			return err.Error()
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	// Les requêtes reçoivent la réponse de leurs paramètres, même dans un autre ordre
+	if got := get("pair=XETHZUSD"); got != "eth" {
+		t.Errorf("réponse ETH = %q", got)
+	}
+	if next, ok := replayer.Next(); !ok || !next.Equal(base.Add(2*time.Minute)) {
+		t.Errorf("Next = %v, %v, attendu la réponse Time", next, ok)
+	}
+	// La réponse XBT sautée reste disponible, avant celle du cycle suivant
+	if got := get("pair=XXBTZUSD"); got != "xbt-1" {
+		t.Errorf("première réponse XBT = %q", got)
+	}
+	if got := get("pair=XXBTZUSD"); got != "xbt-2" {
+		t.Errorf("seconde réponse XBT = %q", got)
+	}
+	if got := get("pair=SOLUSD"); !strings.Contains(got, "aucune réponse enregistrée pour GET /0/public/Ticker?pair=SOLUSD") {
+		t.Errorf("paire non enregistrée: %q", got)
+	}
+
+	// La réponse Time, jamais demandée, est signalée
+	if _, ok := replayer.Next(); ok {
+		t.Error("Next devrait être épuisé après la dernière réponse rejouée")
+	}
+	if unused := replayer.Unused(); len(unused) != 1 || unused[0].Path != "/0/public/Time" || replayer.Remaining() != 1 {
+		t.Errorf("réponses non rejouées = %+v", unused)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------- Archivage des trades individuels -------------------
+
+// Format de date des trades : précision à la microseconde et largeur fixe pour
+// que l'ordre lexicographique corresponde à l'ordre chronologique
+const tradeTimeFormat = "2006-01-02T15:04:05.000000Z"
+
+// Nombre maximal de trades renvoyés par Kraken en une requête
+const krakenTradesPageSize = 1000
+
+// Structure représentant un trade
+type Trade struct {
+	TradeID   int64
+	Price     Decimal
+	Volume    Decimal
+	Time      time.Time
+	Side      string // "buy" ou "sell"
+	OrderType string // "market" ou "limit"
+	Misc      string
+	DedupKey  string // Clé de dédoublonnage d'un trade sans identifiant (WebSocket)
+}
+
+// UnmarshalJSON décode un trade Kraken : [price, volume, time, side, ordertype, misc, trade_id]
+func (t *Trade) UnmarshalJSON(data []byte) error {
+	var raw []interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) < 6 {
+		return fmt.Errorf("trade incomplet: %s", data)
+	}
+
+	timestamp := parseKrakenTime(raw[2])
+	side, _ := raw[3].(string)
+	orderType, _ := raw[4].(string)
+	misc, _ := raw[5].(string)
+
+	t.Price = decimalFromJSON(raw[0])
+	t.Volume = decimalFromJSON(raw[1])
+	seconds, fraction := math.Modf(timestamp)
+	t.Time = time.Unix(int64(seconds), int64(math.Round(fraction*1e6))*1000).UTC()
+	t.Misc = misc
+
+	switch side {
+	case "b":
+		t.Side = "buy"
+	case "s":
+		t.Side = "sell"
+	default:
+		t.Side = side
+	}
+	switch orderType {
+	case "m":
+		t.OrderType = "market"
+	case "l":
+		t.OrderType = "limit"
+	default:
+		t.OrderType = orderType
+	}
+
+	// L'identifiant du trade n'est présent que dans les réponses récentes de l'API
+	if len(raw) > 6 {
+		if id, ok := raw[6].(float64); ok {
+			t.TradeID = int64(id)
+		}
+	}
+	return nil
+}
+
+// parseKrakenTime lit un horodatage Kraken, numérique (REST) ou sous forme de chaîne (WebSocket)
+func parseKrakenTime(v interface{}) float64 {
+	switch value := v.(type) {
+	case float64:
+		return value
+	case string:
+		f, _ := strconv.ParseFloat(value, 64)
+		return f
+	}
+	return 0
+}
+
+// GetTrades récupère les trades d'une paire depuis le curseur since (vide pour les plus récents).
+// last est le curseur à utiliser pour la requête suivante.
+func (c *KrakenClient) GetTrades(ctx context.Context, pair, since string) ([]Trade, string, error) {
+	params := url.Values{}
+	params.Set("pair", pair)
+	if since != "" {
+		params.Set("since", since)
+	}
+
+	var response struct {
+		Error  []string                   `json:"error"`
+		Result map[string]json.RawMessage `json:"result"`
+	}
+	if err := c.get(ctx, "/0/public/Trades", params, &response); err != nil {
+		return nil, "", err
+	}
+
+	var trades []Trade
+	var last string
+	for key, raw := range response.Result {
+		if key == "last" {
+			// Kraken renvoie le curseur sous forme de chaîne (nanosecondes)
+			var number json.Number
+			if err := json.Unmarshal(raw, &number); err != nil {
+				return nil, "", err
+			}
+			last = number.String()
+			continue
+		}
+		if err := json.Unmarshal(raw, &trades); err != nil {
+			return nil, "", err
+		}
+	}
+	return trades, last, nil
+}
+
+// loadTradeCursor lit le dernier curseur enregistré pour une paire (vide s'il n'y en a pas)
+func loadTradeCursor(ctx context.Context, db *sql.DB, pair string) (string, error) {
+	var last string
+	err := db.QueryRowContext(ctx, "SELECT last FROM trade_cursors WHERE pair = ?", pair).Scan(&last)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return last, err
+}
+
+// setDedupKeys attribue leur clé de dédoublonnage aux trades d'un message WebSocket, qui
+// n'ont pas d'identifiant : date du premier trade du message, position du trade dans le
+// message, puis ses date, prix, volume, côté et type d'ordre. Un message renvoyé à
+// l'identique après une reconnexion n'est pas enregistré deux fois, alors que deux
+// exécutions identiques d'un même message restent distinctes.
+func setDedupKeys(trades []Trade) {
+	if len(trades) == 0 {
+		return
+	}
+	first := trades[0].Time.Format(tradeTimeFormat)
+	for i := range trades {
+		t := &trades[i]
+		t.DedupKey = strings.Join([]string{first, strconv.Itoa(i), t.Time.Format(tradeTimeFormat), t.Price.String(), t.Volume.String(), t.Side, t.OrderType}, "|")
+	}
+}
+
+// InsertTrades enregistre des trades et le nouveau curseur dans une même transaction :
+// après un redémarrage, la collecte reprend exactement là où elle s'était arrêtée.
+// Retourne le nombre de trades réellement ajoutés.
+func InsertTrades(ctx context.Context, db *sql.DB, pair string, trades []Trade, last string) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO crypto_trades
+		(pair, trade_id, price, volume, time, side, order_type, misc, dedup_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	inserted := 0
+	for _, t := range trades {
+		var tradeID, dedupKey interface{}
+		if t.TradeID > 0 {
+			tradeID = t.TradeID
+		} else if t.DedupKey != "" {
+			dedupKey = t.DedupKey
+		}
+		result, err := stmt.ExecContext(ctx, pair, tradeID, t.Price, t.Volume, t.Time.Format(tradeTimeFormat), t.Side, t.OrderType, t.Misc, dedupKey)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			inserted++
+		}
+	}
+
+	if last != "" {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO trade_cursors (pair, last, updated_at) VALUES (?, ?, ?)
+			ON CONFLICT(pair) DO UPDATE SET last=excluded.last, updated_at=excluded.updated_at`,
+			pair, last, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return inserted, nil
+}
+
+// CollectTrades récupère tous les nouveaux trades d'une paire depuis le curseur enregistré
+func CollectTrades(ctx context.Context, client *KrakenClient, db *sql.DB, pair PairMapping) (int, error) {
+	cursor, err := loadTradeCursor(ctx, db, pair.AltName)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for {
+		trades, last, err := client.GetTrades(ctx, pair.InternalName, cursor)
+		if err != nil {
+			return total, err
+		}
+		// Stocker avec le nom alternatif, comme les relevés du ticker
+		inserted, err := InsertTrades(ctx, db, pair.AltName, trades, last)
+		if err != nil {
+			return total, err
+		}
+		total += inserted
+
+		// Page incomplète : on a rattrapé le flux des trades
+		if len(trades) < krakenTradesPageSize || last == "" || last == cursor {
+			return total, nil
+		}
+		cursor = last
+	}
+}
+
+// CollectTradesContinuously collecte les trades à intervalles réguliers.
+// Sans liste explicite, les paires sont celles de l'archivage, rafraîchies toutes les heures.
+func CollectTradesContinuously(ctx context.Context, client *KrakenClient, db *sql.DB, pairNames []string, interval time.Duration, wg *sync.WaitGroup) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer wg.Done()
+
+	pairs := &collectorPairs{client: client, names: pairNames}
+
+	for {
+		select {
+		case <-ticker.C:
+			cycleCtx, cancel := context.WithTimeout(ctx, interval)
+			for _, pair := range pairs.Get(cycleCtx) {
+				if cycleCtx.Err() != nil {
+					break
+				}
+				count, err := CollectTrades(cycleCtx, client, db, pair)
+				if err != nil {
+					log.Println("Erreur collecte des trades pour", pair.AltName, ":", err)
+					continue
+				}
+				if count > 0 {
+					log.Printf("Trades archivés : %s | %d nouveau(x)", pair.AltName, count)
+				}
+			}
+			cancel()
+
+		case <-ctx.Done():
+			log.Println("Collecte des trades arrêtée")
+			return
+		}
+	}
+}
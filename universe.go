@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------- Sélection des paires suivies -------------------
+
+// Nombre de paires retenues par défaut par le classement
+const defaultUniverseTop = 20
+
+// Politique de sélection des paires suivies par l'archivage.
+// Les listes acceptent des noms de paires sous toutes leurs formes (XBTUSD, BTC/USD...)
+// et des motifs glob (BTC/*, *EUR), sans tenir compte de la casse.
+type PairSelection struct {
+	Pairs    []string // Liste blanche : paires toujours suivies
+	Exclude  []string // Liste noire : paires jamais suivies, prioritaire sur la liste blanche
+	Quotes   []string // Devises de cotation des paires candidates au classement (toutes si vide)
+	Top      int      // Nombre de paires au plus fort volume ajoutées à la liste blanche (0 : aucune)
+	Currency string   // Devise de référence du classement par volume (USD par défaut)
+}
+
+// splitList découpe une liste séparée par des virgules en ignorant les éléments vides
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// addUniverseFlags déclare les options de sélection des paires
+// et retourne une fonction construisant la sélection une fois les options lues
+func addUniverseFlags(fs *flag.FlagSet) func() PairSelection {
+	pairs := fs.String("pairs", "", "Paires toujours suivies, séparées par des virgules (noms ou motifs glob, ex: XBTUSD,BTC/*)")
+	exclude := fs.String("exclude", "", "Paires jamais suivies, séparées par des virgules (noms ou motifs glob, ex: USDT/*)")
+	quotes := fs.String("quotes", "", "Devises de cotation des paires classées par volume (ex: USD,EUR)")
+	top := fs.Int("top", defaultUniverseTop, "Nombre de paires au plus fort volume ajoutées à la sélection (0 par défaut si -pairs est fourni)")
+	currency := fs.String("ranking-currency", defaultRankingCurrency, "Devise de référence du classement des paires par volume")
+	return func() PairSelection {
+		selection := PairSelection{
+			Pairs:    splitList(*pairs),
+			Exclude:  splitList(*exclude),
+			Quotes:   splitList(*quotes),
+			Top:      *top,
+			Currency: *currency,
+		}
+		// Avec une liste blanche, le classement n'est ajouté que si -top est donné explicitement
+		topSet := false
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "top" {
+				topSet = true
+			}
+		})
+		if len(selection.Pairs) > 0 && !topSet {
+			selection.Top = 0
+		}
+		return selection
+	}
+}
+
+// matchPairPattern indique si l'une des formes de la paire correspond au motif
+func matchPairPattern(pattern string, aliases []string) bool {
+	pattern = strings.ToUpper(strings.TrimSpace(pattern))
+	for _, alias := range aliases {
+		if ok, _ := path.Match(pattern, alias); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyPairPattern indique si la paire correspond à l'un des motifs
+func matchAnyPairPattern(patterns []string, aliases []string) bool {
+	for _, pattern := range patterns {
+		if matchPairPattern(pattern, aliases) {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectAssetPairs applique une politique de sélection au catalogue des paires Kraken.
+// Si le classement est incomplet, la sélection partielle est retournée avec l'erreur.
+func (c *KrakenClient) SelectAssetPairs(ctx context.Context, selection PairSelection) ([]PairMapping, error) {
+	pairs, err := c.marketPairs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return applySelection(pairs, selection, func() ([]PairRanking, error) {
+		return c.fetchMarket(ctx, pairs)
+	})
+}
+
+// applySelection retient parmi les paires d'un exchange celles de la liste blanche, puis
+// les Top paires au plus fort volume converti dans la devise de référence, parmi celles
+// des devises de cotation demandées, sans les paires de la liste noire.
+// market fournit les tickers de toutes les paires (nécessaires aux taux de conversion) ;
+// il n'est appelé que si un classement est demandé. Son erreur est retournée avec la
+// sélection établie sur les tickers obtenus : le classement est alors incomplet.
+func applySelection(pairs []PairMapping, selection PairSelection, market func() ([]PairRanking, error)) ([]PairMapping, error) {
+	quotes := make(map[string]bool)
+	for _, quote := range selection.Quotes {
+		quotes[normalizeSymbol(quote)] = true
+	}
+
+	var whitelisted []PairMapping
+	candidates := make(map[string]PairMapping)
+	matched := make(map[string]bool)
+	for _, pair := range pairs {
+		aliases := newPairSymbol(pair.InternalName, pair.AltName, pair.WSName, pair.Base, pair.Quote).aliases(pair.WSName)
+		if matchAnyPairPattern(selection.Exclude, aliases) {
+			continue
+		}
+
+		isWhitelisted := false
+		for _, pattern := range selection.Pairs {
+			if matchPairPattern(pattern, aliases) {
+				matched[pattern] = true
+				isWhitelisted = true
+			}
+		}
+		switch {
+		case isWhitelisted:
+			whitelisted = append(whitelisted, pair)
+		case len(quotes) == 0 || quotes[pair.Quote]:
+			candidates[pair.AltName] = pair
+		}
+	}
+
+	for _, pattern := range selection.Pairs {
+		if !matched[pattern] {
+			log.Printf("Aucune paire ne correspond à %q (ou paire exclue)", pattern)
+		}
+	}
+
+	sort.Slice(whitelisted, func(i, j int) bool {
+		return whitelisted[i].AltName < whitelisted[j].AltName
+	})
+	selected := whitelisted
+	for _, pair := range whitelisted {
+		log.Printf("Paire sélectionnée: %s (Nom interne: %s, liste explicite)", pair.AltName, pair.InternalName)
+	}
+
+	var marketErr error
+	if selection.Top > 0 && len(candidates) > 0 {
+		var entries []PairRanking
+		entries, marketErr = market()
+		ranking := rankMarket(entries, selection.Currency, time.Now())
+		for _, entry := range ranking.Pairs {
+			if len(selected) >= len(whitelisted)+selection.Top {
+				break
+			}
+			pair, ok := candidates[entry.Pair]
+			if !ok {
+				continue
+			}
+			pair.Volume = entry.ConvertedVolume
+			selected = append(selected, pair)
+			log.Printf("Paire #%d: %s (Nom interne: %s, Volume: %.2f %s)",
+				len(selected)-len(whitelisted), pair.AltName, pair.InternalName, pair.Volume, ranking.Currency)
+		}
+	}
+	return selected, marketErr
+}
+
+// Sélection des paires suivies d'un exchange, gardée en cache entre deux réévaluations
+type selectionCache struct {
+	mu         sync.Mutex
+	pairs      []PairMapping
+	selectedAt time.Time
+}
+
+// get retourne la sélection en cache si elle date de moins de ttl, sinon la réévalue par
+// applySelection sur le catalogue retourné par list. Seule une sélection établie sur un
+// classement complet est mise en cache. Après un classement incomplet, la sélection
+// précédente est conservée ou, à défaut, la sélection partielle utilisée ; si elle est
+// vide, l'erreur est retournée plutôt que d'archiver zéro paire sans le signaler.
+func (s *selectionCache) get(exchange string, ttl time.Duration, selection PairSelection,
+	list func() ([]PairMapping, error), market func(all []PairMapping) ([]PairRanking, error)) ([]PairMapping, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pairs != nil && time.Since(s.selectedAt) < ttl {
+		return s.pairs, nil
+	}
+
+	all, err := list()
+	if err != nil {
+		return nil, err
+	}
+	pairs, err := applySelection(all, selection, func() ([]PairRanking, error) {
+		return market(all)
+	})
+	switch {
+	case err == nil:
+		s.pairs = pairs
+		s.selectedAt = time.Now()
+		return pairs, nil
+	case s.pairs != nil:
+		log.Printf("Classement incomplet (%s), sélection précédente conservée: %v", exchange, err)
+		return s.pairs, nil
+	case len(pairs) > 0:
+		log.Printf("Classement incomplet (%s), sélection partielle utilisée: %v", exchange, err)
+		return pairs, nil
+	}
+	return nil, fmt.Errorf("classement %s impossible, aucune paire sélectionnée: %w", exchange, err)
+}
+
+// RecordPairUniverse compare les paires sélectionnées sur un exchange à l'univers enregistré :
+// les paires ajoutées et retirées sont journalisées et conservées dans pair_universe_changes.
+func RecordPairUniverse(ctx context.Context, db *sql.DB, exchange string, pairs []PairMapping) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT pair FROM pair_universe WHERE exchange = ?", exchange)
+	if err != nil {
+		return err
+	}
+	previous := make(map[string]bool)
+	for rows.Next() {
+		var pair string
+		if err := rows.Scan(&pair); err != nil {
+			rows.Close()
+			return err
+		}
+		previous[pair] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	current := make(map[string]bool)
+	var added, removed []string
+	for _, pair := range pairs {
+		current[pair.AltName] = true
+		if !previous[pair.AltName] && !contains(added, pair.AltName) {
+			added = append(added, pair.AltName)
+		}
+	}
+	for pair := range previous {
+		if !current[pair] {
+			removed = append(removed, pair)
+		}
+	}
+	sort.Strings(removed)
+
+	for _, pair := range added {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO pair_universe (exchange, pair, added_at) VALUES (?, ?, ?)", exchange, pair, now); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO pair_universe_changes (exchange, pair, change, timestamp) VALUES (?, ?, 'added', ?)", exchange, pair, now); err != nil {
+			return err
+		}
+	}
+	for _, pair := range removed {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM pair_universe WHERE exchange = ? AND pair = ?", exchange, pair); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO pair_universe_changes (exchange, pair, change, timestamp) VALUES (?, ?, 'removed', ?)", exchange, pair, now); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, pair := range added {
+		log.Printf("Paire ajoutée à l'univers suivi: %s (%s)", pair, exchange)
+	}
+	for _, pair := range removed {
+		log.Printf("Paire retirée de l'univers suivi: %s (%s)", pair, exchange)
+	}
+	return nil
+}
+
+// contains indique si la liste contient la valeur
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+// universeTestPairs retourne un petit catalogue Kraken avec les volumes de son classement
+func universeTestPairs() ([]PairMapping, []PairRanking) {
+	pairs := []PairMapping{
+		{InternalName: "XXBTZUSD", AltName: "XBTUSD", WSName: "XBT/USD", Base: "BTC", Quote: "USD"},
+		{InternalName: "XXBTZEUR", AltName: "XBTEUR", WSName: "XBT/EUR", Base: "BTC", Quote: "EUR"},
+		{InternalName: "XETHZUSD", AltName: "ETHUSD", WSName: "ETH/USD", Base: "ETH", Quote: "USD"},
+		{InternalName: "XETHXXBT", AltName: "ETHXBT", WSName: "ETH/XBT", Base: "ETH", Quote: "BTC"},
+		{InternalName: "USDTZUSD", AltName: "USDTZUSD", WSName: "USDT/USD", Base: "USDT", Quote: "USD"},
+		{InternalName: "SOLUSD", AltName: "SOLUSD", WSName: "SOL/USD", Base: "SOL", Quote: "USD"},
+	}
+	market := []PairRanking{
+		{Pair: "XBTUSD", Base: "BTC", Quote: "USD", Last: "50000", Volume: "100"},     // 5 000 000 USD
+		{Pair: "XBTEUR", Base: "BTC", Quote: "EUR", Last: "40000", Volume: "50"},      // 2 500 000 USD
+		{Pair: "ETHUSD", Base: "ETH", Quote: "USD", Last: "3000", Volume: "1000"},     // 3 000 000 USD
+		{Pair: "ETHXBT", Base: "ETH", Quote: "BTC", Last: "0.06", Volume: "100"},      // 300 000 USD
+		{Pair: "USDTZUSD", Base: "USDT", Quote: "USD", Last: "1", Volume: "10000000"}, // 10 000 000 USD
+		{Pair: "SOLUSD", Base: "SOL", Quote: "USD", Last: "150", Volume: "1000"},      // 150 000 USD
+	}
+	for i := range market {
+		market[i].QuoteVolume = market[i].Volume.Float64() * market[i].Last.Float64()
+	}
+	return pairs, market
+}
+
+func TestApplySelection(t *testing.T) {
+	pairs, market := universeTestPairs()
+	tests := []struct {
+		name      string
+		selection PairSelection
+		want      string
+	}{
+		{"classement", PairSelection{Top: 3}, "USDTZUSD,XBTUSD,ETHUSD"},
+		{"liste blanche seule", PairSelection{Pairs: []string{"ETHUSD", "btc-usd"}}, "ETHUSD,XBTUSD"},
+		{"motif sur le symbole usuel", PairSelection{Pairs: []string{"BTC/*"}}, "XBTEUR,XBTUSD"},
+		{"motif sur le nom WebSocket", PairSelection{Pairs: []string{"*/xbt"}}, "ETHXBT"},
+		{"motif sur le nom alternatif", PairSelection{Pairs: []string{"*EUR"}}, "XBTEUR"},
+		{"liste noire prioritaire", PairSelection{Pairs: []string{"BTC/*"}, Exclude: []string{"*/EUR"}}, "XBTUSD"},
+		{"liste noire sur le classement", PairSelection{Top: 2, Exclude: []string{"USDT/*"}}, "XBTUSD,ETHUSD"},
+		{"liste blanche puis classement", PairSelection{Pairs: []string{"SOL/USD"}, Top: 2, Exclude: []string{"USDT*"}}, "SOLUSD,XBTUSD,ETHUSD"},
+		{"devises de cotation", PairSelection{Top: 2, Quotes: []string{"eur", "XBT"}}, "XBTEUR,ETHXBT"},
+		{"classement en euros", PairSelection{Top: 1, Currency: "EUR", Exclude: []string{"USDT/*", "XBTUSD"}}, "ETHUSD"},
+		{"motif sans correspondance", PairSelection{Pairs: []string{"DOGE/*"}}, ""},
+	}
+	for _, tt := range tests {
+		calls := 0
+		selected, err := applySelection(pairs, tt.selection, func() ([]PairRanking, error) {
+			calls++
+			return market, nil
+		})
+		var names []string
+		for _, pair := range selected {
+			names = append(names, pair.AltName)
+		}
+		if got := strings.Join(names, ","); err != nil || got != tt.want {
+			t.Errorf("%s: sélection = %q, %v, attendu %q", tt.name, got, err, tt.want)
+		}
+		// Les tickers ne sont récupérés que si un classement est demandé
+		if wantCalls := min(tt.selection.Top, 1); calls != wantCalls {
+			t.Errorf("%s: %d récupération(s) des tickers, attendu %d", tt.name, calls, wantCalls)
+		}
+	}
+}
+
+func TestApplySelectionIncompleteMarket(t *testing.T) {
+	pairs, market := universeTestPairs()
+	marketErr := errors.New("tickers indisponibles")
+
+	// La sélection établie sur les tickers obtenus est retournée avec l'erreur
+	selected, err := applySelection(pairs, PairSelection{Pairs: []string{"SOLUSD"}, Top: 1}, func() ([]PairRanking, error) {
+		return market[1:2], marketErr
+	})
+	if err != marketErr || len(selected) != 2 || selected[0].AltName != "SOLUSD" || selected[1].AltName != "XBTEUR" {
+		t.Errorf("sélection = %v, %v", selected, err)
+	}
+}
+
+func TestSelectionCache(t *testing.T) {
+	pairs, market := universeTestPairs()
+	marketErr := errors.New("tickers indisponibles")
+	list := func() ([]PairMapping, error) { return pairs, nil }
+	partial := func([]PairMapping) ([]PairRanking, error) { return market[1:2], marketErr }
+	complete := func([]PairMapping) ([]PairRanking, error) { return market, nil }
+	failing := func([]PairMapping) ([]PairRanking, error) { return nil, marketErr }
+	altNames := func(pairs []PairMapping) string {
+		var names []string
+		for _, pair := range pairs {
+			names = append(names, pair.AltName)
+		}
+		return strings.Join(names, ",")
+	}
+	selection := PairSelection{Pairs: []string{"SOLUSD"}, Top: 1}
+
+	// Sans sélection précédente, la sélection partielle est utilisée mais pas mise en cache
+	var cache selectionCache
+	if got, err := cache.get("kraken", time.Hour, selection, list, partial); err != nil || altNames(got) != "SOLUSD,XBTEUR" {
+		t.Errorf("sélection partielle = %v, %v", got, err)
+	}
+	if got, err := cache.get("kraken", time.Hour, selection, list, complete); err != nil || altNames(got) != "SOLUSD,USDTZUSD" {
+		t.Errorf("sélection complète = %v, %v", got, err)
+	}
+	// Une sélection complète est conservée pendant ttl, puis après un classement en échec
+	if got, _ := cache.get("kraken", time.Hour, selection, list, failing); altNames(got) != "SOLUSD,USDTZUSD" {
+		t.Errorf("sélection en cache = %v", got)
+	}
+	if got, err := cache.get("kraken", 0, selection, list, failing); err != nil || altNames(got) != "SOLUSD,USDTZUSD" {
+		t.Errorf("sélection précédente = %v, %v", got, err)
+	}
+
+	// Classement seul en échec au premier cycle : aucune paire, l'erreur est retournée
+	var empty selectionCache
+	if got, err := empty.get("kraken", time.Hour, PairSelection{Top: 2}, list, failing); !errors.Is(err, marketErr) || got != nil {
+		t.Errorf("sélection sans classement = %v, %v, attendu l'erreur du classement", got, err)
+	}
+}
+
+func TestAddUniverseFlags(t *testing.T) {
+	parse := func(args ...string) PairSelection {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		build := addUniverseFlags(fs)
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return build()
+	}
+
+	if s := parse(); s.Top != defaultUniverseTop || len(s.Pairs) != 0 || s.Currency != defaultRankingCurrency {
+		t.Errorf("sélection par défaut = %+v", s)
+	}
+	// Avec une liste blanche, le classement n'est ajouté que si -top est explicite
+	if s := parse("-pairs", "XBTUSD, BTC/* ,", "-exclude", "USDT/*"); s.Top != 0 || strings.Join(s.Pairs, "|") != "XBTUSD|BTC/*" || s.Exclude[0] != "USDT/*" {
+		t.Errorf("sélection avec -pairs = %+v", s)
+	}
+	if s := parse("-pairs", "XBTUSD", "-top", "5", "-quotes", "USD,EUR"); s.Top != 5 || len(s.Quotes) != 2 {
+		t.Errorf("sélection avec -pairs et -top = %+v", s)
+	}
+}
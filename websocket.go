@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ------------------- Ingestion temps réel (WebSocket Kraken) -------------------
+
+// URL de l'API WebSocket publique de Kraken (format v1, mêmes champs que l'API REST)
+const krakenWSURL = "wss://ws.kraken.com"
+
+// Bornes du délai d'attente entre deux tentatives de reconnexion
+const (
+	wsMinBackoff = 1 * time.Second
+	wsMaxBackoff = 1 * time.Minute
+)
+
+// Structure de configuration et d'état de l'ingestion WebSocket.
+// Les tickers et carnets reçus sont gardés en mémoire et écrits dans la base
+// toutes les FlushInterval ; les trades sont écrits dès leur réception.
+type WSIngestor struct {
+	URL           string        // URL du serveur WebSocket (modifiable pour les tests)
+	DB            *sql.DB       // Base dans laquelle écrire
+	Pairs         []PairMapping // Paires à suivre (WSName doit être renseigné)
+	Channels      []string      // Canaux : "ticker", "trade", "book"
+	BookDepth     int           // Profondeur du carnet (10, 25, 100, 500 ou 1000)
+	FlushInterval time.Duration // Intervalle d'écriture des tickers et carnets
+	ReadTimeout   time.Duration // Sans message (heartbeat compris) pendant ce délai, on se reconnecte
+
+	mu       sync.Mutex
+	altNames map[string]string      // wsname -> nom alternatif utilisé pour le stockage
+	tickers  map[string]*TickerInfo // Dernier ticker reçu, en attente d'écriture
+	books    map[string]*localBook  // Carnet maintenu à partir des mises à jour
+}
+
+// Carnet d'ordres reconstruit localement à partir des messages "book"
+type localBook struct {
+	asks    map[Decimal]BookLevel // Niveaux indexés par prix normalisé
+	bids    map[Decimal]BookLevel
+	updated bool // Modifié depuis la dernière écriture
+}
+
+// NewWSIngestor prépare une ingestion WebSocket avec les valeurs par défaut
+func NewWSIngestor(db *sql.DB, pairs []PairMapping, channels []string) *WSIngestor {
+	return &WSIngestor{
+		URL:           krakenWSURL,
+		DB:            db,
+		Pairs:         pairs,
+		Channels:      channels,
+		BookDepth:     defaultBookDepth,
+		FlushInterval: 10 * time.Second,
+		ReadTimeout:   30 * time.Second,
+	}
+}
+
+// Run maintient la connexion WebSocket jusqu'à l'annulation de ctx,
+// en se reconnectant avec un délai croissant en cas d'erreur.
+func (w *WSIngestor) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	w.mu.Lock()
+	w.altNames = make(map[string]string)
+	w.tickers = make(map[string]*TickerInfo)
+	w.books = make(map[string]*localBook)
+	for _, pair := range w.Pairs {
+		w.altNames[pair.WSName] = pair.AltName
+	}
+	w.mu.Unlock()
+
+	// Écriture périodique des tickers et carnets
+	flushDone := make(chan struct{})
+	go func() {
+		defer close(flushDone)
+		ticker := time.NewTicker(w.FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.flush(ctx)
+			case <-ctx.Done():
+				// Dernière écriture des données reçues, qui ne doit pas être annulée
+				w.flush(context.WithoutCancel(ctx))
+				return
+			}
+		}
+	}()
+
+	backoff := wsMinBackoff
+	for {
+		received, err := w.connectAndServe(ctx)
+		select {
+		case <-ctx.Done():
+			<-flushDone
+			log.Println("Ingestion WebSocket arrêtée")
+			return
+		default:
+		}
+
+		// La connexion a fonctionné : repartir du délai minimal
+		if received {
+			backoff = wsMinBackoff
+		}
+		log.Printf("Connexion WebSocket interrompue (%v), reconnexion dans %s", err, backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			<-flushDone
+			log.Println("Ingestion WebSocket arrêtée")
+			return
+		}
+		backoff *= 2
+		if backoff > wsMaxBackoff {
+			backoff = wsMaxBackoff
+		}
+	}
+}
+
+// connectAndServe ouvre une connexion, s'abonne aux canaux et lit les messages
+// jusqu'à une erreur. received indique si au moins un message de données a été reçu.
+func (w *WSIngestor) connectAndServe(ctx context.Context) (received bool, err error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, w.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// Fermer la connexion à l'arrêt pour débloquer la lecture
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := w.subscribe(conn); err != nil {
+		return false, err
+	}
+	log.Printf("Connecté à %s : %d paire(s), canaux %s", w.URL, len(w.Pairs), strings.Join(w.Channels, ","))
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(w.ReadTimeout))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return received, err
+		}
+		isData, err := w.handleMessage(ctx, data)
+		if err != nil {
+			log.Println("Message WebSocket ignoré:", err)
+			continue
+		}
+		received = received || isData
+	}
+}
+
+// subscribe envoie un abonnement par canal pour toutes les paires suivies.
+// Appelé à chaque connexion, ce qui rétablit les abonnements après une reconnexion.
+func (w *WSIngestor) subscribe(conn *websocket.Conn) error {
+	var wsNames []string
+	for _, pair := range w.Pairs {
+		if pair.WSName != "" {
+			wsNames = append(wsNames, pair.WSName)
+		}
+	}
+	if len(wsNames) == 0 {
+		return fmt.Errorf("aucune paire à suivre")
+	}
+
+	for _, channel := range w.Channels {
+		subscription := map[string]interface{}{"name": channel}
+		if channel == "book" {
+			subscription["depth"] = w.BookDepth
+		}
+		message := map[string]interface{}{
+			"event":        "subscribe",
+			"pair":         wsNames,
+			"subscription": subscription,
+		}
+		if err := conn.WriteJSON(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleMessage traite un message reçu et indique s'il s'agissait de données de marché
+func (w *WSIngestor) handleMessage(ctx context.Context, data []byte) (bool, error) {
+	// Messages d'événement : heartbeat, statut du système, statut d'abonnement
+	if len(data) > 0 && data[0] == '{' {
+		var event struct {
+			Event        string `json:"event"`
+			Status       string `json:"status"`
+			Pair         string `json:"pair"`
+			ErrorMessage string `json:"errorMessage"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			return false, err
+		}
+		if event.Event == "subscriptionStatus" && event.Status == "error" {
+			log.Printf("Abonnement WebSocket refusé pour %s: %s", event.Pair, event.ErrorMessage)
+		}
+		return false, nil
+	}
+
+	// Messages de données : [channelID, payload..., channelName, pair]
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return false, err
+	}
+	if len(raw) < 4 {
+		return false, fmt.Errorf("message incomplet: %s", data)
+	}
+	var channelName, wsName string
+	if err := json.Unmarshal(raw[len(raw)-2], &channelName); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(raw[len(raw)-1], &wsName); err != nil {
+		return false, err
+	}
+	payloads := raw[1 : len(raw)-2]
+
+	w.mu.Lock()
+	pair, ok := w.altNames[wsName]
+	w.mu.Unlock()
+	if !ok {
+		return false, fmt.Errorf("paire inattendue: %s", wsName)
+	}
+
+	switch {
+	case channelName == "ticker":
+		var info TickerInfo
+		if err := json.Unmarshal(payloads[0], &info); err != nil {
+			return false, err
+		}
+		w.mu.Lock()
+		w.tickers[pair] = &info
+		w.mu.Unlock()
+
+	case channelName == "trade":
+		var trades []Trade
+		if err := json.Unmarshal(payloads[0], &trades); err != nil {
+			return false, err
+		}
+		// Pas de curseur en WebSocket : le flux est continu tant que la connexion tient,
+		// seul un message renvoyé après une reconnexion est écarté
+		setDedupKeys(trades)
+		if _, err := InsertTrades(ctx, w.DB, pair, trades, ""); err != nil {
+			return false, err
+		}
+
+	case strings.HasPrefix(channelName, "book"):
+		for _, payload := range payloads {
+			if err := w.applyBookUpdate(pair, payload); err != nil {
+				return false, err
+			}
+		}
+
+	default:
+		return false, fmt.Errorf("canal inattendu: %s", channelName)
+	}
+	return true, nil
+}
+
+// applyBookUpdate applique un instantané ("as"/"bs") ou une mise à jour ("a"/"b") au carnet local
+func (w *WSIngestor) applyBookUpdate(pair string, payload json.RawMessage) error {
+	var update struct {
+		AsksSnapshot []BookLevel `json:"as"`
+		BidsSnapshot []BookLevel `json:"bs"`
+		Asks         []BookLevel `json:"a"`
+		Bids         []BookLevel `json:"b"`
+	}
+	if err := json.Unmarshal(payload, &update); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	book, ok := w.books[pair]
+	if !ok || update.AsksSnapshot != nil || update.BidsSnapshot != nil {
+		// Un instantané remplace entièrement le carnet (abonnement ou reconnexion)
+		book = &localBook{asks: make(map[Decimal]BookLevel), bids: make(map[Decimal]BookLevel)}
+		w.books[pair] = book
+	}
+
+	applyBookLevels(book.asks, update.AsksSnapshot)
+	applyBookLevels(book.bids, update.BidsSnapshot)
+	applyBookLevels(book.asks, update.Asks)
+	applyBookLevels(book.bids, update.Bids)
+
+	// Ne garder que les niveaux dans la profondeur suivie
+	truncateBookSide(book.asks, w.BookDepth, false)
+	truncateBookSide(book.bids, w.BookDepth, true)
+	book.updated = true
+	return nil
+}
+
+// applyBookLevels met à jour un côté du carnet ; un volume nul supprime le niveau
+func applyBookLevels(side map[Decimal]BookLevel, levels []BookLevel) {
+	for _, level := range levels {
+		if level.Volume.IsZero() {
+			delete(side, level.Price.Normalize())
+		} else {
+			side[level.Price.Normalize()] = level
+		}
+	}
+}
+
+// sortedBookSide retourne les niveaux triés du meilleur au moins bon prix
+func sortedBookSide(side map[Decimal]BookLevel, descending bool) []BookLevel {
+	levels := make([]BookLevel, 0, len(side))
+	for _, level := range side {
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if descending {
+			return levels[i].Price.Cmp(levels[j].Price) > 0
+		}
+		return levels[i].Price.Cmp(levels[j].Price) < 0
+	})
+	return levels
+}
+
+// truncateBookSide supprime les niveaux au-delà de la profondeur suivie
+func truncateBookSide(side map[Decimal]BookLevel, depth int, descending bool) {
+	if len(side) <= depth {
+		return
+	}
+	for _, level := range sortedBookSide(side, descending)[depth:] {
+		delete(side, level.Price.Normalize())
+	}
+}
+
+// flush écrit les tickers et carnets reçus depuis la dernière écriture,
+// par le même chemin que l'archivage périodique
+func (w *WSIngestor) flush(ctx context.Context) {
+	w.mu.Lock()
+	tickers := w.tickers
+	w.tickers = make(map[string]*TickerInfo)
+
+	var snapshots []*BookSnapshot
+	now := time.Now().UTC().Format(time.RFC3339)
+	for pair, book := range w.books {
+		if !book.updated {
+			continue
+		}
+		book.updated = false
+		snapshots = append(snapshots, &BookSnapshot{
+			Pair:  pair,
+			Time:  now,
+			Depth: w.BookDepth,
+			Asks:  sortedBookSide(book.asks, false),
+			Bids:  sortedBookSide(book.bids, true),
+		})
+	}
+	w.mu.Unlock()
+
+	for pair, info := range tickers {
+		StoreTicker(ctx, w.DB, pair, info)
+	}
+	for _, snapshot := range snapshots {
+		if err := InsertBookSnapshot(ctx, w.DB, snapshot); err != nil {
+			log.Println("Erreur lors de l'insertion du carnet pour", snapshot.Pair, ":", err)
+		}
+	}
+}
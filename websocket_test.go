@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Messages du format v1 de Kraken
+const (
+	wsTestTicker = `[42,{"a":["50000.50000",1,"1.000"],"b":["50000.00000",2,"2.000"],"c":["50000.20000","0.1"],` +
+		`"v":["10.5","20.25"],"p":["50000","50000"],"t":[5,10],"l":["49000","48000"],"h":["51000","52000"],"o":["49500","49000"]},"ticker","XBT/USD"]`
+	wsTestTrade     = `[43,[["50000.10000","0.50000000","1735732800.123456","b","m",""]],"trade","XBT/USD"]`
+	wsTestNextTrade = `[43,[["50001.00000","0.25000000","1735732801.500000","s","l",""],["50001.00000","0.25000000","1735732801.500000","s","l",""]],"trade","XBT/USD"]`
+	wsTestBook      = `[44,{"as":[["50000.5","1.0","1735732800.1"]],"bs":[["50000.0","2.0","1735732800.1"]]},"book-10","XBT/USD"]`
+	wsTestHeartbeat = `{"event":"heartbeat"}`
+)
+
+func TestWSIngestor(t *testing.T) {
+	db := InitDB(filepath.Join(t.TempDir(), "crypto.db"))
+	defer db.Close()
+
+	var mu sync.Mutex
+	var subscriptions []string // Canaux souscrits, par connexion
+	done := make(chan struct{})
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		// Un abonnement par canal, pour toutes les paires suivies
+		var channels []string
+		for range 3 {
+			var message struct {
+				Event        string   `json:"event"`
+				Pair         []string `json:"pair"`
+				Subscription struct {
+					Name  string `json:"name"`
+					Depth int    `json:"depth"`
+				} `json:"subscription"`
+			}
+			if err := conn.ReadJSON(&message); err != nil {
+				t.Error(err)
+				return
+			}
+			if message.Event != "subscribe" || strings.Join(message.Pair, ",") != "XBT/USD" {
+				t.Errorf("abonnement inattendu: %+v", message)
+			}
+			channels = append(channels, message.Subscription.Name)
+		}
+		mu.Lock()
+		subscriptions = append(subscriptions, strings.Join(channels, ","))
+		reconnected := len(subscriptions) > 1
+		mu.Unlock()
+
+		send := func(messages ...string) {
+			for _, message := range messages {
+				if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+					t.Error(err)
+				}
+			}
+		}
+		send(`{"event":"systemStatus","status":"online"}`, wsTestTicker, wsTestTrade, wsTestBook)
+		if !reconnected {
+			// Coupure de la première connexion : le client doit se reconnecter et se réabonner
+			return
+		}
+
+		// Après la reconnexion, Kraken renvoie le dernier trade avant les suivants
+		send(wsTestNextTrade)
+		// Les heartbeats maintiennent la connexion au-delà du délai de lecture
+		for range 6 {
+			send(wsTestHeartbeat)
+			time.Sleep(50 * time.Millisecond)
+		}
+		close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	ingestor := NewWSIngestor(db, []PairMapping{{InternalName: "XXBTZUSD", AltName: "XBTUSD", WSName: "XBT/USD"}}, []string{"ticker", "trade", "book"})
+	ingestor.URL = "ws" + strings.TrimPrefix(server.URL, "http")
+	ingestor.FlushInterval = 20 * time.Millisecond
+	ingestor.ReadTimeout = 200 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go ingestor.Run(ctx, &wg)
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("le client ne s'est pas reconnecté")
+	}
+	cancel()
+	wg.Wait()
+
+	mu.Lock()
+	if strings.Join(subscriptions, " ") != "ticker,trade,book ticker,trade,book" {
+		t.Errorf("abonnements par connexion = %q", subscriptions)
+	}
+	mu.Unlock()
+
+	// Le trade renvoyé après la reconnexion n'est enregistré qu'une fois ; les deux
+	// exécutions identiques du message suivant le sont toutes les deux
+	var trades int
+	if err := db.QueryRow("SELECT COUNT(*) FROM crypto_trades WHERE pair = 'XBTUSD'").Scan(&trades); err != nil || trades != 3 {
+		t.Errorf("%d trade(s) enregistré(s), attendu 3 (%v)", trades, err)
+	}
+
+	var ask, volumeToday Decimal
+	if err := db.QueryRow("SELECT ask_price, volume_today FROM crypto_history WHERE exchange = 'kraken' AND pair = 'XBTUSD' ORDER BY id DESC LIMIT 1").Scan(&ask, &volumeToday); err != nil || ask != "50000.50000" || volumeToday != "10.5" {
+		t.Errorf("ticker écrit = %s / %s (%v)", ask, volumeToday, err)
+	}
+
+	snapshot, err := GetNearestBookSnapshot(context.Background(), db, "XBTUSD", time.Now())
+	if err != nil || snapshot == nil || len(snapshot.Asks) != 1 || snapshot.Asks[0].Price != "50000.5" || snapshot.Bids[0].Volume != "2.0" {
+		t.Errorf("carnet écrit = %+v (%v)", snapshot, err)
+	}
+}